@@ -0,0 +1,235 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define apiSnippet as the JSON representation of a snippet
+// Kept separate from models.Snippet so the API format is explicit
+// Content and Language are those of the first file, for clients from
+// before snippets had several files
+type apiSnippet struct {
+	ID               int               `json:"id"`
+	Slug             string            `json:"slug"`
+	Title            string            `json:"title"`
+	Content          string            `json:"content"`
+	Language         string            `json:"language"`
+	Files            []snippetFileForm `json:"files"`
+	Tags             []string          `json:"tags"`
+	Visibility       string            `json:"visibility"`
+	BurnAfterReading bool              `json:"burn_after_reading"`
+	HasPassword      bool              `json:"has_password"`
+	Created          time.Time         `json:"created"`
+	Expires          *time.Time        `json:"expires"`
+	UserID           int               `json:"user_id"`
+	Author           string            `json:"author,omitempty"`
+	ForkedFrom       int               `json:"forked_from,omitempty"`
+	Forks            int               `json:"forks,omitempty"`
+	Stars            int               `json:"stars"`
+}
+
+func newAPISnippet(s *models.Snippet) apiSnippet {
+	first := s.Files[0]
+
+	return apiSnippet{
+		ID:               s.ID,
+		Slug:             s.Slug,
+		Title:            s.Title,
+		Content:          first.Content,
+		Language:         first.Language,
+		Files:            fileForms(s.Files),
+		Tags:             s.Tags,
+		Visibility:       s.Visibility,
+		BurnAfterReading: s.BurnAfterReading,
+		HasPassword:      s.HasPassword(),
+		Created:          s.Created,
+		Expires:          s.Expires,
+		UserID:           s.UserID,
+		Author:           s.UserName,
+		ForkedFrom:       s.ForkedFrom,
+		Forks:            s.Forks,
+		Stars:            s.Stars,
+	}
+}
+
+// Define apiMetadata type for the pagination details of a listing
+type apiMetadata struct {
+	CurrentPage  int `json:"current_page"`
+	PageSize     int `json:"page_size"`
+	LastPage     int `json:"last_page"`
+	TotalRecords int `json:"total_records"`
+}
+
+func newAPIMetadata(p *pagination) apiMetadata {
+	return apiMetadata{
+		CurrentPage:  p.CurrentPage,
+		PageSize:     p.PageSize,
+		LastPage:     p.LastPage,
+		TotalRecords: p.TotalRecords,
+	}
+}
+
+// GET /api/v1/snippets?page=N
+// Returns a page of the latest snippets, newest first
+func (app *application) apiSnippetList(w http.ResponseWriter, r *http.Request) {
+	page, err := app.readPage(r)
+	if err != nil {
+		app.apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	snippets, total, err := app.snippets.Latest(listPageSize, (page-1)*listPageSize)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	list := make([]apiSnippet, 0, len(snippets))
+	for _, s := range snippets {
+		list = append(list, newAPISnippet(s))
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{
+		"snippets": list,
+		"metadata": newAPIMetadata(newPagination(r, page, listPageSize, total)),
+	})
+}
+
+// GET /api/v1/snippets/:slug
+// Public snippets can still be fetched by their numeric ID
+// Burn after reading snippets are burned like on the HTML view page
+// Password-protected snippets can only be fetched by their owner
+func (app *application) apiSnippetGet(w http.ResponseWriter, r *http.Request) {
+	snippet, _, err := app.findSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	if !app.isUnlocked(r, snippet) {
+		app.apiError(w, http.StatusForbidden, "this snippet is password protected")
+		return
+	}
+
+	_, err = app.burnSnippet(r, snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	app.writeJSON(w, http.StatusOK, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+// POST /api/v1/snippets
+// Takes the same fields as the HTML create form
+// A snippet with a single unnamed file can be sent with content and
+// language instead of files
+// The languages, visibility and expires are optional and default to plain
+// text, public and "365d", like the HTML create form
+func (app *application) apiSnippetCreate(w http.ResponseWriter, r *http.Request) {
+	form := snippetCreateForm{
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
+	}
+
+	err := app.decodeJSONBody(w, r, &form)
+	if err != nil {
+		if errors.Is(err, errUnsupportedMediaType) {
+			app.apiError(w, http.StatusUnsupportedMediaType, err.Error())
+		} else {
+			app.apiError(w, http.StatusBadRequest, err.Error())
+		}
+		return
+	}
+
+	if len(form.Files) == 0 {
+		form.Files = []snippetFileForm{{Language: form.Language, Content: form.Content}}
+	}
+
+	// Return the validator errors with 422 Unprocessable Entity
+	form.validate()
+	if !form.Valid() {
+		app.writeJSON(w, http.StatusUnprocessableEntity, apiErrorResponse{
+			Error:          "the request contains invalid fields",
+			FieldErrors:    form.FieldErrors,
+			NonFieldErrors: form.NonFieldErrors,
+		})
+		return
+	}
+
+	forkedFrom, err := app.forkedFromID(r, form.ForkedFrom)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	slug, err := app.snippets.Insert(models.NewSnippet{
+		UserID:           app.authenticatedUserID(r),
+		Title:            form.Title,
+		Files:            snippetFiles(form.Files),
+		Tags:             form.Tags.Normalized(),
+		Visibility:       form.Visibility,
+		Password:         form.Password,
+		BurnAfterReading: form.BurnAfterReading,
+		Expires:          expiryTime(form.Expires, form.ExpiresAt, time.Now()),
+		ForkedFrom:       forkedFrom,
+	})
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	// Fetch the new snippet so the response has the generated fields
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("/api/v1/snippets/%s", slug))
+	app.writeJSON(w, http.StatusCreated, map[string]any{"snippet": newAPISnippet(snippet)})
+}
+
+// DELETE /api/v1/snippets/:slug
+// Only the owner can delete a snippet
+func (app *application) apiSnippetDelete(w http.ResponseWriter, r *http.Request) {
+	snippet, _, err := app.findSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.apiError(w, http.StatusForbidden, "you do not have permission to delete this snippet")
+		return
+	}
+
+	err = app.snippets.Delete(snippet.ID, snippet.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.apiNotFound(w)
+		} else {
+			app.apiServerError(w, err)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
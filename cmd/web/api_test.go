@@ -0,0 +1,588 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Build a request to the test server with a JSON body, or none if body is
+// empty
+// Cookie authenticated requests need the CSRF token in the X-CSRF-Token
+// header, so csrfToken is sent there if it isn't empty
+func newAPIRequest(t *testing.T, ts *testServer, method, urlPath, body, csrfToken string) *http.Request {
+	req, err := http.NewRequest(method, ts.URL+urlPath, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if csrfToken != "" {
+		req.Header.Set("X-CSRF-Token", csrfToken)
+	}
+
+	return req
+}
+
+// Decode a JSON response body into dst
+func decodeJSON(t *testing.T, body string, dst any) {
+	err := json.Unmarshal([]byte(body), dst)
+	if err != nil {
+		t.Fatalf("decoding %q: %s", body, err)
+	}
+}
+
+func TestAPISnippetList(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, title := range []string{"Public", "Private"} {
+		_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: title, Files: oneFile("go", "package main"), Visibility: strings.ToLower(title), Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("List", func(t *testing.T) {
+		code, header, body := ts.get(t, "/api/v1/snippets")
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if ct := header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("want content type %q; got %q", "application/json", ct)
+		}
+
+		var rs struct {
+			Snippets []apiSnippet `json:"snippets"`
+			Metadata apiMetadata  `json:"metadata"`
+		}
+		decodeJSON(t, body, &rs)
+
+		if len(rs.Snippets) != 1 || rs.Snippets[0].Title != "Public" || rs.Snippets[0].Language != "go" {
+			t.Errorf("want only the public snippet; got %+v", rs.Snippets)
+		}
+
+		want := apiMetadata{CurrentPage: 1, PageSize: listPageSize, LastPage: 1, TotalRecords: 1}
+		if rs.Metadata != want {
+			t.Errorf("want metadata %+v; got %+v", want, rs.Metadata)
+		}
+	})
+
+	for _, page := range []string{"0", "foo", "9223372036854775807"} {
+		t.Run("Invalid page "+page, func(t *testing.T) {
+			code, _, body := ts.get(t, "/api/v1/snippets?page="+page)
+
+			if code != http.StatusBadRequest {
+				t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+			}
+
+			var rs apiErrorResponse
+			decodeJSON(t, body, &rs)
+
+			if rs.Error == "" {
+				t.Error("want an error message")
+			}
+		})
+	}
+}
+
+func TestAPISnippetGet(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Hello", Files: oneFile("plaintext", "World"), Tags: []string{"greeting"}, Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateSlug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Secret", Files: oneFile("plaintext", "Hidden"), Visibility: "private", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lockedSlug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Locked", Files: oneFile("plaintext", "hunter2"), Visibility: "public", Password: "open sesame", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Valid slug", "/api/v1/snippets/" + slug, http.StatusOK},
+		{"Password protected", "/api/v1/snippets/" + lockedSlug, http.StatusForbidden},
+		{"Private", "/api/v1/snippets/" + privateSlug, http.StatusNotFound},
+		{"Non-existent slug", "/api/v1/snippets/doesnotexist", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if code != http.StatusOK {
+				var rs apiErrorResponse
+				decodeJSON(t, body, &rs)
+
+				if rs.Error == "" {
+					t.Error("want an error message")
+				}
+				return
+			}
+
+			var rs struct {
+				Snippet apiSnippet `json:"snippet"`
+			}
+			decodeJSON(t, body, &rs)
+
+			if rs.Snippet.Slug != slug || rs.Snippet.Content != "World" || rs.Snippet.Author != "Alice" || len(rs.Snippet.Tags) != 1 {
+				t.Errorf("want the snippet; got %+v", rs.Snippet)
+			}
+		})
+	}
+}
+
+func TestAPISnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	csrfTokens := map[*testServer]string{}
+	for _, ts := range []*testServer{anonymous, alice} {
+		_, _, body := ts.get(t, "/user/login")
+		csrfTokens[ts] = extractCSRFToken(t, body)
+	}
+
+	valid := `{"title": "Hello", "content": "World", "expires": "7d"}`
+
+	tests := []struct {
+		name            string
+		ts              *testServer
+		contentType     string
+		body            string
+		wantCode        int
+		wantError       string
+		wantFieldErrors map[string]string
+	}{
+		{"Anonymous", anonymous, "application/json", valid, http.StatusUnauthorized, "you must be authenticated", nil},
+		{"Wrong content type", alice, "text/plain", valid, http.StatusUnsupportedMediaType, "Content-Type header must be application/json", nil},
+		{"Badly-formed JSON", alice, "application/json", `{"title": `, http.StatusBadRequest, "badly-formed JSON", nil},
+		{"Unknown field", alice, "application/json", `{"title": "Hello", "owner": 2}`, http.StatusBadRequest, `unknown field "owner"`, nil},
+		{"Two values", alice, "application/json", valid + valid, http.StatusBadRequest, "single JSON value", nil},
+		{"Too large", alice, "application/json", `{"title": "Hello", "content": "` + strings.Repeat("a", maxJSONBodyBytes) + `"}`, http.StatusBadRequest, "request body too large", nil},
+		{
+			"Invalid fields",
+			alice,
+			"application/json",
+			`{"title": "", "content": "World", "visibility": "secret", "expires": "30s"}`,
+			http.StatusUnprocessableEntity,
+			"the request contains invalid fields",
+			map[string]string{"title": "This field cannot be blank", "visibility": "This field must equal public, unlisted or private", "expires": "This field must be a supported expiry"},
+		},
+		{"Valid", alice, "application/json", valid, http.StatusCreated, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAPIRequest(t, tt.ts, http.MethodPost, "/api/v1/snippets", tt.body, csrfTokens[tt.ts])
+			req.Header.Set("Content-Type", tt.contentType)
+
+			code, header, body := tt.ts.do(t, req)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d: %s", tt.wantCode, code, body)
+			}
+
+			if code == http.StatusCreated {
+				var rs struct {
+					Snippet apiSnippet `json:"snippet"`
+				}
+				decodeJSON(t, body, &rs)
+
+				if want := "/api/v1/snippets/" + rs.Snippet.Slug; header.Get("Location") != want {
+					t.Errorf("want location %q; got %q", want, header.Get("Location"))
+				}
+
+				if rs.Snippet.Title != "Hello" || rs.Snippet.Content != "World" || rs.Snippet.Language != "plaintext" || rs.Snippet.Visibility != "public" || rs.Snippet.UserID != 1 {
+					t.Errorf("want the new snippet; got %+v", rs.Snippet)
+				}
+				return
+			}
+
+			var rs apiErrorResponse
+			decodeJSON(t, body, &rs)
+
+			if !strings.Contains(rs.Error, tt.wantError) {
+				t.Errorf("want error containing %q; got %q", tt.wantError, rs.Error)
+			}
+
+			for field, want := range tt.wantFieldErrors {
+				if got := rs.FieldErrors[field]; got != want {
+					t.Errorf("want %s error %q; got %q", field, want, got)
+				}
+			}
+			if len(rs.FieldErrors) != len(tt.wantFieldErrors) {
+				t.Errorf("want %d field errors; got %v", len(tt.wantFieldErrors), rs.FieldErrors)
+			}
+		})
+	}
+
+	t.Run("Default expiry", func(t *testing.T) {
+		req := newAPIRequest(t, alice, http.MethodPost, "/api/v1/snippets", `{"title": "Hello", "content": "World"}`, csrfTokens[alice])
+		req.Header.Set("Content-Type", "application/json")
+
+		code, _, body := alice.do(t, req)
+
+		if code != http.StatusCreated {
+			t.Fatalf("want %d; got %d: %s", http.StatusCreated, code, body)
+		}
+
+		var rs struct {
+			Snippet apiSnippet `json:"snippet"`
+		}
+		decodeJSON(t, body, &rs)
+
+		want := time.Now().AddDate(0, 0, 365)
+		if rs.Snippet.Expires == nil || rs.Snippet.Expires.Before(want.Add(-time.Minute)) || rs.Snippet.Expires.After(want.Add(time.Minute)) {
+			t.Errorf("want expires around %v; got %v", want, rs.Snippet.Expires)
+		}
+	})
+}
+
+func TestAPISnippetDelete(t *testing.T) {
+	app := newTestApplication(t)
+
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Hello", Files: oneFile("plaintext", "World"), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrfTokens := map[*testServer]string{}
+	for _, ts := range []*testServer{alice, bob} {
+		_, _, body := ts.get(t, "/user/login")
+		csrfTokens[ts] = extractCSRFToken(t, body)
+	}
+
+	tests := []struct {
+		name     string
+		ts       *testServer
+		slug     string
+		wantCode int
+	}{
+		{"Not the owner", bob, slug, http.StatusForbidden},
+		{"Owner", alice, slug, http.StatusNoContent},
+		{"Already deleted", alice, slug, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAPIRequest(t, tt.ts, http.MethodDelete, "/api/v1/snippets/"+tt.slug, "", csrfTokens[tt.ts])
+
+			code, _, body := tt.ts.do(t, req)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d: %s", tt.wantCode, code, body)
+			}
+		})
+	}
+}
+
+// Create an API token through the account tokens page and return it
+func createAPIToken(t *testing.T, ts *testServer, name string) string {
+	_, _, body := ts.get(t, "/account/tokens")
+
+	form := url.Values{}
+	form.Add("csrf_token", extractCSRFToken(t, body))
+	form.Add("name", name)
+
+	code, _, body := ts.postForm(t, "/account/tokens", form)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	matches := newTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no token found in body")
+	}
+
+	return matches[1]
+}
+
+var newTokenRX = regexp.MustCompile(`<pre><code>([A-Za-z0-9_-]+)</code></pre>`)
+
+func TestAccountTokens(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	t.Run("Anonymous", func(t *testing.T) {
+		code, header, _ := anonymous.get(t, "/account/tokens")
+
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+			t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+		}
+	})
+
+	t.Run("Blank name", func(t *testing.T) {
+		_, _, body := alice.get(t, "/account/tokens")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, body))
+		form.Add("name", " ")
+
+		code, _, body := alice.postForm(t, "/account/tokens", form)
+
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+		}
+
+		if !strings.Contains(body, "This field cannot be blank") {
+			t.Error("want body to contain the field error")
+		}
+	})
+
+	token := createAPIToken(t, alice, "Laptop")
+
+	t.Run("Listed once", func(t *testing.T) {
+		_, _, body := alice.get(t, "/account/tokens")
+
+		if !strings.Contains(body, "Laptop") {
+			t.Error("want body to contain the token name")
+		}
+
+		// The plain-text token is only shown when it's created
+		if strings.Contains(body, token) {
+			t.Error("want body not to contain the token")
+		}
+
+		_, _, body = bob.get(t, "/account/tokens")
+		if strings.Contains(body, "Laptop") {
+			t.Error("want other users not to see the token")
+		}
+	})
+
+	t.Run("Revoke", func(t *testing.T) {
+		tokens, err := app.tokens.List(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		revokePath := fmt.Sprintf("/account/tokens/revoke/%d", tokens[0].ID)
+
+		tests := []struct {
+			name     string
+			ts       *testServer
+			wantCode int
+		}{
+			{"Other user's token", bob, http.StatusNotFound},
+			{"Own token", alice, http.StatusSeeOther},
+			{"Already revoked", alice, http.StatusNotFound},
+		}
+
+		for _, tt := range tests {
+			_, _, body := tt.ts.get(t, "/account/tokens")
+
+			form := url.Values{}
+			form.Add("csrf_token", extractCSRFToken(t, body))
+
+			code, _, _ := tt.ts.postForm(t, revokePath, form)
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+			}
+		}
+	})
+}
+
+func TestAPITokenAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Bearer requests come from a server with no session
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	token := createAPIToken(t, alice, "CLI")
+
+	body := `{"title": "From the CLI", "content": "Hello", "expires": "7d"}`
+
+	tests := []struct {
+		name          string
+		authorization string
+		wantCode      int
+	}{
+		// Without a token or a session, nosurf turns the request away first
+		{"No token", "", http.StatusBadRequest},
+		{"Unknown token", "Bearer not-a-real-token", http.StatusUnauthorized},
+		{"Wrong scheme", "Basic " + token, http.StatusUnauthorized},
+		{"Missing token", "Bearer ", http.StatusUnauthorized},
+		{"Valid token", "Bearer " + token, http.StatusCreated},
+		{"Lowercase scheme", "bearer " + token, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// No CSRF token, which bearer requests don't need
+			req := newAPIRequest(t, anonymous, http.MethodPost, "/api/v1/snippets", body, "")
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+
+			code, header, rsBody := anonymous.do(t, req)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d: %s", tt.wantCode, code, rsBody)
+			}
+
+			if code == http.StatusUnauthorized && header.Get("WWW-Authenticate") != "Bearer" {
+				t.Errorf("want WWW-Authenticate %q; got %q", "Bearer", header.Get("WWW-Authenticate"))
+			}
+
+			if code == http.StatusCreated {
+				var rs struct {
+					Snippet apiSnippet `json:"snippet"`
+				}
+				decodeJSON(t, rsBody, &rs)
+
+				if rs.Snippet.UserID != 1 {
+					t.Errorf("want the snippet to belong to Alice; got user %d", rs.Snippet.UserID)
+				}
+			}
+		})
+	}
+
+	t.Run("Token without session", func(t *testing.T) {
+		req := newAPIRequest(t, anonymous, http.MethodGet, "/api/v1/snippets", "", "")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		code, header, _ := anonymous.do(t, req)
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if vary := header.Values("Vary"); !strings.Contains(strings.Join(vary, ","), "Authorization") {
+			t.Errorf("want Vary to contain Authorization; got %q", vary)
+		}
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		tokens, err := app.tokens.List(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, page := alice.get(t, "/account/tokens")
+
+		form := url.Values{}
+		form.Add("csrf_token", extractCSRFToken(t, page))
+
+		code, _, _ := alice.postForm(t, fmt.Sprintf("/account/tokens/revoke/%d", tokens[0].ID), form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		req := newAPIRequest(t, anonymous, http.MethodPost, "/api/v1/snippets", body, "")
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		code, _, _ = anonymous.do(t, req)
+		if code != http.StatusUnauthorized {
+			t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+		}
+	})
+}
+
+// Only bearer requests skip the CSRF check, cookie authenticated API
+// requests can be forged cross-site like any form
+func TestAPICSRF(t *testing.T) {
+	app := newTestApplication(t)
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	_, _, page := alice.get(t, "/user/login")
+	csrfToken := extractCSRFToken(t, page)
+
+	body := `{"title": "Hello", "content": "World", "expires": "7d"}`
+
+	tests := []struct {
+		name      string
+		csrfToken string
+		wantCode  int
+	}{
+		{"No CSRF token", "", http.StatusBadRequest},
+		{"Wrong CSRF token", "bm90IHRoZSByaWdodCB0b2tlbiBhdCBhbGwgc29ycnk=", http.StatusBadRequest},
+		{"Valid CSRF token", csrfToken, http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := newAPIRequest(t, alice, http.MethodPost, "/api/v1/snippets", body, tt.csrfToken)
+
+			code, header, rsBody := alice.do(t, req)
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d: %s", tt.wantCode, code, rsBody)
+			}
+
+			// Failed checks get a JSON error like the rest of the API
+			if code == http.StatusBadRequest {
+				if ct := header.Get("Content-Type"); ct != "application/json" {
+					t.Errorf("want content type %q; got %q", "application/json", ct)
+				}
+
+				var rs apiErrorResponse
+				decodeJSON(t, rsBody, &rs)
+
+				if rs.Error != "invalid or missing CSRF token" {
+					t.Errorf("want error %q; got %q", "invalid or missing CSRF token", rs.Error)
+				}
+			}
+		})
+	}
+
+	// Only the valid request made a snippet
+	_, total, err := app.snippets.Latest(listPageSize, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Errorf("want 1 snippet; got %d", total)
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/internal/validator"
+)
+
+// Define collectionForm struct for creating and editing a collection
+type collectionForm struct {
+	Name                string `form:"name"`
+	Description         string `form:"description"`
+	Visibility          string `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// Validate the form contents
+func (form *collectionForm) validate() {
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+	form.CheckField(validator.MaxChars(form.Description, 1000), "description", "This field cannot be more than 1000 characters long")
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+}
+
+// Define collectionSnippetForm struct for the forms that add, remove and
+// move the snippets of a collection
+// Collection and Snippet are slugs, and Direction is "up" or "down"
+type collectionSnippetForm struct {
+	Collection string `form:"collection"`
+	Snippet    string `form:"snippet"`
+	Direction  string `form:"direction"`
+}
+
+// Fetch the collection with the slug from the URL
+// Private collections are only found by their owner
+// If there is no such collection, an error response is sent and ok is false
+func (app *application) collectionFromURL(w http.ResponseWriter, r *http.Request) (collection *models.Collection, ok bool) {
+	slug := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	collection, err := app.collections.Get(slug)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if !collection.VisibleTo(app.authenticatedUserID(r)) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// Fetch the collection with the slug from the URL and check that it belongs
+// to the authenticated user. If not, an error response is sent and ok is false
+func (app *application) ownedCollection(w http.ResponseWriter, r *http.Request) (collection *models.Collection, ok bool) {
+	collection, ok = app.collectionFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if collection.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return collection, true
+}
+
+// Return the snippets of a collection that the user may see listed
+// The owner sees all of them. Anyone else doesn't see the private ones,
+// or the burn after reading ones, which browsing the collection would destroy
+func (app *application) collectionSnippets(r *http.Request, collection *models.Collection) ([]*models.Snippet, error) {
+	snippets, err := app.collections.Snippets(collection.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	userID := app.authenticatedUserID(r)
+	if collection.UserID == userID {
+		return snippets, nil
+	}
+
+	visible := []*models.Snippet{}
+	for _, s := range snippets {
+		if s.VisibleTo(userID) && !s.BurnAfterReading {
+			visible = append(visible, s)
+		}
+	}
+	return visible, nil
+}
+
+// Render the page listing the user's collections, with the form to create one
+func (app *application) renderCollections(w http.ResponseWriter, r *http.Request, status int, form collectionForm) {
+	collections, err := app.collections.ByUser(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collections = collections
+	data.Form = form
+
+	app.render(w, status, "collections.tmpl.html", data)
+}
+
+// GET /collections
+func (app *application) collectionList(w http.ResponseWriter, r *http.Request) {
+	app.renderCollections(w, r, http.StatusOK, collectionForm{Visibility: models.VisibilityPublic})
+}
+
+// POST /collections
+func (app *application) collectionCreatePost(w http.ResponseWriter, r *http.Request) {
+	var form collectionForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		app.renderCollections(w, r, http.StatusUnprocessableEntity, form)
+		return
+	}
+
+	slug, err := app.collections.Insert(app.authenticatedUserID(r), form.Name, form.Description, form.Visibility)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully created!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%s", slug), http.StatusSeeOther)
+}
+
+// GET /collection/:slug
+func (app *application) collectionView(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.collectionFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	snippets, err := app.collectionSnippets(r, collection)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Snippets = snippets
+
+	app.render(w, http.StatusOK, "collection.tmpl.html", data)
+}
+
+// GET /collection/:slug/edit
+func (app *application) collectionEdit(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Collection = collection
+	data.Form = collectionForm{
+		Name:        collection.Name,
+		Description: collection.Description,
+		Visibility:  collection.Visibility,
+	}
+
+	app.render(w, http.StatusOK, "collection_edit.tmpl.html", data)
+}
+
+// POST /collection/:slug/edit
+func (app *application) collectionEditPost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	// Keep the current visibility if none is submitted
+	form := collectionForm{
+		Visibility: collection.Visibility,
+	}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.validate()
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Collection = collection
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "collection_edit.tmpl.html", data)
+		return
+	}
+
+	err = app.collections.Update(collection.ID, collection.UserID, form.Name, form.Description, form.Visibility)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%s", collection.Slug), http.StatusSeeOther)
+}
+
+// POST /collection/:slug/delete
+// The snippets in the collection are kept
+func (app *application) collectionDeletePost(w http.ResponseWriter, r *http.Request) {
+	collection, ok := app.ownedCollection(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.collections.Delete(collection.ID, collection.UserID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Collection successfully deleted!")
+
+	http.Redirect(w, r, "/collections", http.StatusSeeOther)
+}
+
+// Fetch the collection from the URL and the snippet named by the posted
+// form, for changing the collection's snippets
+// If either is missing or the collection isn't the user's, an error
+// response is sent and ok is false
+func (app *application) collectionSnippetFromForm(w http.ResponseWriter, r *http.Request) (collection *models.Collection, snippet *models.Snippet, form collectionSnippetForm, ok bool) {
+	collection, ok = app.ownedCollection(w, r)
+	if !ok {
+		return nil, nil, form, false
+	}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return nil, nil, form, false
+	}
+
+	snippet, err = app.snippets.GetBySlug(form.Snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, nil, form, false
+	}
+
+	return collection, snippet, form, true
+}
+
+// POST /collection/:slug/remove
+// Takes the snippet out of the collection without deleting it
+func (app *application) collectionRemovePost(w http.ResponseWriter, r *http.Request) {
+	collection, snippet, _, ok := app.collectionSnippetFromForm(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.collections.RemoveSnippet(collection.ID, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet removed from the collection!")
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%s", collection.Slug), http.StatusSeeOther)
+}
+
+// POST /collection/:slug/move
+// Moves the snippet one place up or down the collection
+func (app *application) collectionMovePost(w http.ResponseWriter, r *http.Request) {
+	collection, snippet, form, ok := app.collectionSnippetFromForm(w, r)
+	if !ok {
+		return
+	}
+
+	if !validator.PermittedValue(form.Direction, "up", "down") {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	err := app.collections.MoveSnippet(collection.ID, snippet.ID, form.Direction == "up")
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/collection/%s", collection.Slug), http.StatusSeeOther)
+}
+
+// POST /snippet/collect/:slug
+// Adds the snippet to the end of one of the user's collections
+// Only the owner of a snippet can add it to their collections
+func (app *application) snippetCollectPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form collectionSnippetForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	collection, err := app.collections.Get(form.Collection)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if collection.UserID != snippet.UserID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.collections.AddSnippet(collection.ID, snippet.ID)
+	if err != nil {
+		if errors.Is(err, models.ErrDuplicateSnippet) {
+			app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("This snippet is already in %s.", collection.Name))
+			http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", fmt.Sprintf("Snippet added to %s!", collection.Name))
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/internal/validator"
+)
+
+// How deep replies can nest, counting top level comments as 1
+// It keeps threads readable, and keeps the chain of parent_id cascades well
+// under the 15 levels InnoDB follows, so deleting a snippet never fails
+const maxCommentDepth = 6
+
+// Define commentForm struct for the comment and reply forms on the view page
+// Parent is the ID of the comment being replied to, or 0
+// File and Line are the optional anchor, with File the 1-based position of
+// one of the snippet's files. A blank Line means no anchor
+type commentForm struct {
+	Content             string `form:"content"`
+	Parent              int    `form:"parent"`
+	File                int    `form:"file"`
+	Line                int    `form:"line"`
+	validator.Validator `form:"-"`
+}
+
+// Validate the form contents against the snippet being commented on
+func (form *commentForm) validate(snippet *models.Snippet) {
+	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Content, 2000), "content", "This field cannot be more than 2000 characters long")
+
+	if form.Line == 0 {
+		form.File = 0
+		return
+	}
+
+	if form.File < 1 || form.File > len(snippet.Files) {
+		form.AddFieldError("file", "This field must be one of the snippet's files")
+		return
+	}
+
+	lines := lineCount(snippet.Files[form.File-1].Content)
+	form.CheckField(form.Line >= 1 && form.Line <= lines, "line", fmt.Sprintf("This field must be a line number from 1 to %d", lines))
+}
+
+// Return the number of lines in content, as numbered on the view page
+func lineCount(content string) int {
+	return strings.Count(strings.TrimSuffix(content, "\n"), "\n") + 1
+}
+
+// Return the id of a line on the view page, for anchoring comments
+// file is the 1-based position of the file
+func lineID(file, line int) string {
+	return fmt.Sprintf("%s%d", lineIDPrefix(file), line)
+}
+
+// Return what the ids of the lines of a file start with
+func lineIDPrefix(file int) string {
+	return fmt.Sprintf("f%d-L", file)
+}
+
+// Return how deep in its thread a comment is, 1 for a top level comment
+// Counting stops once it's past maxCommentDepth
+func (app *application) commentDepth(comment *models.Comment) (int, error) {
+	depth := 1
+	for comment.ParentID != 0 && depth <= maxCommentDepth {
+		parent, err := app.comments.Get(comment.ParentID)
+		if err != nil {
+			return 0, err
+		}
+		comment = parent
+		depth++
+	}
+	return depth, nil
+}
+
+// POST /snippet/comment/:slug
+// Replies go in their parent's thread and aren't anchored to a line
+func (app *application) snippetCommentPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	var form commentForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	if form.Parent != 0 {
+		parent, err := app.comments.Get(form.Parent)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			app.serverError(w, err)
+			return
+		}
+
+		// The parent may have been deleted while the reply was written
+		// The form is shown again without it, so it can be posted as a new
+		// comment instead
+		if err != nil || parent.SnippetID != snippet.ID || parent.Deleted {
+			form.AddFieldError("parent", "The comment you're replying to has been deleted")
+			form.Parent = 0
+		} else {
+			depth, err := app.commentDepth(parent)
+			if err != nil {
+				app.serverError(w, err)
+				return
+			}
+
+			if depth >= maxCommentDepth {
+				form.AddFieldError("parent", fmt.Sprintf("Replies can't be nested more than %d deep", maxCommentDepth))
+				form.Parent = 0
+			}
+		}
+
+		form.File, form.Line = 0, 0
+	}
+
+	form.validate(snippet)
+
+	if !form.Valid() {
+		data, err := app.snippetViewData(r, snippet, false)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "view.tmpl.html", data)
+		return
+	}
+
+	id, err := app.comments.Insert(snippet.ID, app.authenticatedUserID(r), form.Parent, form.File, form.Line, form.Content)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment successfully posted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comment-%d", snippet.Slug, id), http.StatusSeeOther)
+}
+
+// POST /comment/delete/:id
+// The author of a comment and the owner of the snippet can delete it
+func (app *application) commentDeletePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	comment, err := app.comments.Get(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	if comment.Deleted {
+		app.notFound(w)
+		return
+	}
+
+	snippet, err := app.snippets.Get(comment.SnippetID)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	userID := app.authenticatedUserID(r)
+	if comment.UserID != userID && snippet.UserID != userID {
+		app.clientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = app.comments.Delete(id)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Comment successfully deleted!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s#comments", snippet.Slug), http.StatusSeeOther)
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Define a custom type for request context keys to avoid collisions
+type contextKey string
+
+// Holds the ID of a user authenticated by an API token
+const tokenUserIDContextKey = contextKey("tokenUserID")
+
+// Return the ID of the user authenticated by an API token, or 0 if the
+// request didn't use one
+func tokenUserID(r *http.Request) int {
+	id, _ := r.Context().Value(tokenUserIDContextKey).(int)
+	return id
+}
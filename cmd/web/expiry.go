@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Define expiryChoice type for the expires field of the create form
+// It's one of the presets, "never" or "custom", in which case the expiry
+// comes from the expires_at field
+type expiryChoice string
+
+const (
+	expiryNever  expiryChoice = "never"
+	expiryCustom expiryChoice = "custom"
+)
+
+// How long a snippet lasts for each preset
+var expiryPresets = map[expiryChoice]time.Duration{
+	"10m":  10 * time.Minute,
+	"1h":   time.Hour,
+	"1d":   24 * time.Hour,
+	"7d":   7 * 24 * time.Hour,
+	"30d":  30 * 24 * time.Hour,
+	"365d": 365 * 24 * time.Hour,
+}
+
+// expiryChoices lists the valid expires values, for validation
+var expiryChoices = []expiryChoice{"10m", "1h", "1d", "7d", "30d", "365d", expiryNever, expiryCustom}
+
+// The API used to take the expiry as a number of days, so a JSON number
+// N is still accepted and means "Nd"
+func (c *expiryChoice) UnmarshalJSON(data []byte) error {
+	var days int
+	if err := json.Unmarshal(data, &days); err == nil {
+		*c = expiryChoice(fmt.Sprintf("%dd", days))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*c = expiryChoice(s)
+	return nil
+}
+
+// Layouts accepted for a custom expiry
+// The first is what a datetime-local input submits, which has no time
+// zone and is read as UTC like every other time the app shows
+var expiresAtLayouts = []string{"2006-01-02T15:04", time.RFC3339}
+
+// Parse a custom expiry in any of expiresAtLayouts
+func parseExpiresAt(value string) (time.Time, bool) {
+	for _, layout := range expiresAtLayouts {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// Work out when a snippet created at now with this choice expires
+// Returns nil for snippets that never expire
+// The choice must already be valid, and expiresAt parseable for "custom"
+func expiryTime(choice expiryChoice, expiresAt string, now time.Time) *time.Time {
+	switch choice {
+	case expiryNever:
+		return nil
+	case expiryCustom:
+		t, _ := parseExpiresAt(expiresAt)
+		return &t
+	default:
+		t := now.Add(expiryPresets[choice])
+		return &t
+	}
+}
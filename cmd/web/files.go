@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/koller-m/snippetbox/internal/highlight"
+	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/internal/validator"
+)
+
+// Maximum number of files in a snippet
+const maxSnippetFiles = 10
+
+// Define snippetFileForm struct for one of the file blocks of the create and
+// edit forms
+// They're posted with keys like "files[0].content"
+type snippetFileForm struct {
+	Filename string `form:"filename" json:"filename"`
+	Language string `form:"language" json:"language"`
+	Content  string `form:"content" json:"content"`
+}
+
+// Return the form key of a field of the file block at index
+func fileField(index int, name string) string {
+	return fmt.Sprintf("files[%d].%s", index, name)
+}
+
+// Drop the file blocks that were left empty and default their languages
+// If they're all empty the first one is kept, so the form has somewhere to
+// say the content can't be blank
+func cleanFileForms(files []snippetFileForm) []snippetFileForm {
+	cleaned := []snippetFileForm{}
+
+	for _, f := range files {
+		f.Filename = strings.TrimSpace(f.Filename)
+		if f.Language == "" {
+			f.Language = highlight.DefaultLanguage
+		}
+
+		if f.Filename != "" || validator.NotBlank(f.Content) {
+			cleaned = append(cleaned, f)
+		}
+	}
+
+	if len(cleaned) == 0 {
+		blank := snippetFileForm{Language: highlight.DefaultLanguage}
+		if len(files) > 0 && files[0].Language != "" {
+			blank.Language = files[0].Language
+		}
+		cleaned = append(cleaned, blank)
+	}
+
+	return cleaned
+}
+
+// Validate the file blocks of a form
+// Filenames are optional, but have to be unique within the snippet and
+// can't contain slashes, as they're used for downloads
+func validateFileForms(v *validator.Validator, files []snippetFileForm) {
+	v.CheckField(len(files) <= maxSnippetFiles, "files", fmt.Sprintf("A snippet can't have more than %d files", maxSnippetFiles))
+
+	seen := map[string]bool{}
+
+	for i, f := range files {
+		v.CheckField(validator.MaxChars(f.Filename, 100), fileField(i, "filename"), "This field cannot be more than 100 characters long")
+		v.CheckField(!strings.ContainsAny(f.Filename, `/\`), fileField(i, "filename"), "This field cannot contain slashes")
+		v.CheckField(f.Filename == "" || !seen[f.Filename], fileField(i, "filename"), "Another file already has this name")
+		v.CheckField(validator.PermittedValue(f.Language, highlight.LanguageIDs()...), fileField(i, "language"), "This field must be a supported language")
+		v.CheckField(validator.NotBlank(f.Content), fileField(i, "content"), "This field cannot be blank")
+
+		seen[f.Filename] = true
+	}
+}
+
+// Convert the file blocks of a valid form for the snippet model
+func snippetFiles(files []snippetFileForm) []*models.SnippetFile {
+	snippetFiles := make([]*models.SnippetFile, 0, len(files))
+	for _, f := range files {
+		snippetFiles = append(snippetFiles, &models.SnippetFile{
+			Filename: f.Filename,
+			Language: f.Language,
+			Content:  f.Content,
+		})
+	}
+	return snippetFiles
+}
+
+// Convert a snippet's files to file blocks, to pre-fill a form
+func fileForms(files []*models.SnippetFile) []snippetFileForm {
+	forms := make([]snippetFileForm, 0, len(files))
+	for _, f := range files {
+		forms = append(forms, snippetFileForm{
+			Filename: f.Filename,
+			Language: f.Language,
+			Content:  f.Content,
+		})
+	}
+	return forms
+}
+
+// Define fileBlock type for rendering one of the file blocks of a form
+// Index is a string so the blank block in the form's <template> element
+// can have a placeholder for the script to fill in
+type fileBlock struct {
+	snippetFileForm
+	Index         string
+	FilenameError string
+	LanguageError string
+	ContentError  string
+	Languages     []highlight.Language
+}
+
+// Return the file blocks of a form along with their errors
+func fileBlocks(files []snippetFileForm, fieldErrors map[string]string, languages []highlight.Language) []fileBlock {
+	blocks := make([]fileBlock, 0, len(files))
+	for i, f := range files {
+		blocks = append(blocks, fileBlock{
+			snippetFileForm: f,
+			Index:           fmt.Sprint(i),
+			FilenameError:   fieldErrors[fileField(i, "filename")],
+			LanguageError:   fieldErrors[fileField(i, "language")],
+			ContentError:    fieldErrors[fileField(i, "content")],
+			Languages:       languages,
+		})
+	}
+	return blocks
+}
+
+// Return the empty file block that the script copies to add a file
+func blankFileBlock(languages []highlight.Language) fileBlock {
+	return fileBlock{
+		snippetFileForm: snippetFileForm{Language: highlight.DefaultLanguage},
+		Index:           "__index__",
+		Languages:       languages,
+	}
+}
+
+// Return the name of a file for headings, "File 2" if it has no filename
+func fileName(file *models.SnippetFile, index int) string {
+	if file.Filename != "" {
+		return file.Filename
+	}
+	return fmt.Sprintf("File %d", index+1)
+}
+
+// Return the 1-based position of the file at index, as used in forms
+func filePosition(index int) int {
+	return index + 1
+}
+
+// Return the link to the raw or download endpoint for one of a snippet's
+// files, which are numbered from 1 in URLs
+func snippetFileURL(action, slug string, index int) string {
+	return fmt.Sprintf("/snippet/%s/%s/%d", action, slug, index+1)
+}
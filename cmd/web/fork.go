@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// GET /snippet/fork/:slug
+// Shows the create form filled in with a copy of the snippet
+// Saving it creates a new snippet owned by the user that records which
+// snippet it was forked from
+func (app *application) snippetFork(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Form = snippetCreateForm{
+		Title:      snippet.Title,
+		Files:      fileForms(snippet.Files),
+		Tags:       newTagList(snippet.Tags),
+		Visibility: snippet.Visibility,
+		Expires:    "365d",
+		ForkedFrom: snippet.Slug,
+	}
+
+	app.render(w, http.StatusOK, "create.tmpl.html", data)
+}
+
+// Return the ID of the snippet with the given slug, to record a fork of it
+// Returns 0 if slug is empty or names a snippet the user can't read, and
+// the new snippet is then saved as an ordinary one
+func (app *application) forkedFromID(r *http.Request, slug string) (int, error) {
+	if slug == "" {
+		return 0, nil
+	}
+
+	original, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	// The same checks as readableSnippet()
+	userID := app.authenticatedUserID(r)
+	if !original.VisibleTo(userID) || !app.isUnlocked(r, original) || (original.BurnAfterReading && original.UserID != userID) {
+		return 0, nil
+	}
+
+	return original.ID, nil
+}
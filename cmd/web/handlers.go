@@ -5,19 +5,60 @@ import (
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/julienschmidt/httprouter"
+	"github.com/koller-m/snippetbox/internal/highlight"
 	"github.com/koller-m/snippetbox/internal/models"
 	"github.com/koller-m/snippetbox/internal/validator"
-
-	"github.com/julienschmidt/httprouter"
 )
 
 // Define snippetCreateForm struct for the form data and validation errors
+// The json tags let the API decode request bodies into the same struct
+// Content and Language are only read from the API, for snippets with a
+// single unnamed file
+// ForkedFrom is the slug of the snippet being forked, if any
 type snippetCreateForm struct {
-	Title               string `form:"title"`
-	Content             string `form:"content"`
-	Expires             int    `form:"expires"`
-	validator.Validator `form:"-"`
+	Title               string            `form:"title" json:"title"`
+	Files               []snippetFileForm `form:"files" json:"files"`
+	Tags                tagList           `form:"tags" json:"tags"`
+	Content             string            `form:"-" json:"content"`
+	Language            string            `form:"-" json:"language"`
+	Visibility          string            `form:"visibility" json:"visibility"`
+	BurnAfterReading    bool              `form:"burn_after_reading" json:"burn_after_reading"`
+	Password            string            `form:"password" json:"password"`
+	Expires             expiryChoice      `form:"expires" json:"expires"`
+	ExpiresAt           string            `form:"expires_at" json:"expires_at"`
+	ForkedFrom          string            `form:"forked_from" json:"forked_from"`
+	validator.Validator `form:"-" json:"-"`
+}
+
+// Validate the form contents, after dropping empty file blocks
+// Shared by the HTML and JSON handlers
+func (form *snippetCreateForm) validate() {
+	form.Files = cleanFileForms(form.Files)
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	validateFileForms(&form.Validator, form.Files)
+	validateTags(&form.Validator, form.Tags)
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+	form.CheckField(validator.PermittedValue(form.Expires, expiryChoices...), "expires", "This field must be a supported expiry")
+
+	// A custom expiry needs a valid date and time in the future
+	if form.Expires == expiryCustom {
+		expiresAt, ok := parseExpiresAt(form.ExpiresAt)
+		form.CheckField(validator.NotBlank(form.ExpiresAt), "expires_at", "This field cannot be blank")
+		form.CheckField(ok, "expires_at", "This field must be a valid date and time")
+		form.CheckField(expiresAt.After(time.Now()), "expires_at", "This field must be in the future")
+	}
+
+	// The password is optional, but bcrypt only uses the first 72 bytes
+	if form.Password != "" {
+		form.CheckField(validator.MinChars(form.Password, 8), "password", "This field must be at least 8 characters long")
+		form.CheckField(len(form.Password) <= 72, "password", "This field cannot be more than 72 bytes long")
+	}
 }
 
 // Create userSignupForm struct
@@ -28,6 +69,28 @@ type userSignupForm struct {
 	validator.Validator `form:"-"`
 }
 
+// Create snippetEditForm struct
+// The expiry of a snippet can't be changed once it's created
+type snippetEditForm struct {
+	Title               string            `form:"title"`
+	Files               []snippetFileForm `form:"files"`
+	Tags                tagList           `form:"tags"`
+	Visibility          string            `form:"visibility"`
+	validator.Validator `form:"-"`
+}
+
+// Create snippetUnlockForm struct
+type snippetUnlockForm struct {
+	Password            string `form:"password"`
+	validator.Validator `form:"-"`
+}
+
+// Create tokenCreateForm struct
+type tokenCreateForm struct {
+	Name                string `form:"name"`
+	validator.Validator `form:"-"`
+}
+
 type userLoginForm struct {
 	Email               string `form:"email"`
 	Password            string `form:"password"`
@@ -38,15 +101,38 @@ type userLoginForm struct {
 // Writes a byte slice containing
 // "Hello from Snippetbox" as the response body
 func (app *application) home(w http.ResponseWriter, r *http.Request) {
-	snippets, err := app.snippets.Latest()
+	// Read the page number from the query string, e.g. /?page=2
+	page, err := app.readPage(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	// Newest first, or most starred first with ?sort=stars
+	sort := r.URL.Query().Get("sort")
+
+	list := app.snippets.Latest
+	switch sort {
+	case sortNewest:
+	case sortStars:
+		list = app.snippets.MostStarred
+	default:
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := list(listPageSize, (page-1)*listPageSize)
 	if err != nil {
 		app.serverError(w, err)
+		return
 	}
 
 	// Call newTemplateData() to get a templateData struct containing current year
-	// And add the snippet slice to it
+	// And add the snippet slice and page links to it
 	data := app.newTemplateData(r)
 	data.Snippets = snippets
+	data.Sort = sort
+	data.Pagination = newPagination(r, page, listPageSize, total)
 
 	// Use render helper
 	app.render(w, http.StatusOK, "home.tmpl.html", data)
@@ -54,21 +140,21 @@ func (app *application) home(w http.ResponseWriter, r *http.Request) {
 
 // Add snippetView handler function
 func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
-	params := httprouter.ParamsFromContext(r.Context())
+	snippet, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
 
-	// Extract the value of the id param from query string
-	// Convert it to int using strconv.Atoi()
-	// If it can't be converted or value is less than 1, return 404
-	id, err := strconv.Atoi(params.ByName("id"))
-	if err != nil || id < 1 {
-		app.notFound(w)
+	// Ask for the passphrase instead, without burning the snippet
+	if !app.isUnlocked(r, snippet) {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = snippetUnlockForm{}
+		app.render(w, http.StatusOK, "unlock.tmpl.html", data)
 		return
 	}
 
-	// Use SnippetModel's Get method to retrieve the data for a specific record
-	// Based on its ID
-	// If not found, return a 404 Not Found response
-	snippet, err := app.snippets.Get(id)
+	burned, err := app.burnSnippet(r, snippet)
 	if err != nil {
 		if errors.Is(err, models.ErrNoRecord) {
 			app.notFound(w)
@@ -78,25 +164,164 @@ func (app *application) snippetView(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// The page mustn't be kept anywhere once the snippet is gone
+	if burned {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+
+	data, err := app.snippetViewData(r, snippet, burned)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+	data.Form = commentForm{}
+
+	app.render(w, http.StatusOK, "view.tmpl.html", data)
+}
+
+// Gather everything the snippet page shows besides the comment form
+// burned says whether viewing the snippet just deleted it
+func (app *application) snippetViewData(r *http.Request, snippet *models.Snippet, burned bool) (*templateData, error) {
+	var err error
+
 	data := app.newTemplateData(r)
 	data.Snippet = snippet
+	data.Burned = burned
+
+	// Only link to the original of a fork if the user could find it anyway,
+	// so forks don't give away the slugs of unlisted snippets
+	if snippet.ForkedFrom != 0 {
+		original, err := app.snippets.Get(snippet.ForkedFrom)
+		if err != nil && !errors.Is(err, models.ErrNoRecord) {
+			return nil, err
+		}
+		if err == nil && (original.Listed() || original.UserID == app.authenticatedUserID(r)) {
+			data.Original = original
+		}
+	}
 
-	app.render(w, http.StatusOK, "view.tmpl.html", data)
+	// Logged in users can star or unstar the snippet
+	if app.isAuthenticated(r) && !burned {
+		data.Starred, err = app.stars.Exists(app.authenticatedUserID(r), snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// The owner can add the snippet to one of their collections
+	if snippet.UserID == app.authenticatedUserID(r) && !burned {
+		data.Collections, err = app.collections.ByUser(snippet.UserID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// A burned snippet is gone, so there's nothing left to discuss
+	if !burned {
+		data.Comments, err = app.comments.ForSnippet(snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return data, nil
+}
+
+// POST /snippet/unlock/:slug
+// A correct passphrase is remembered in the session until it ends
+func (app *application) snippetUnlockPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	var form snippetUnlockForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Password), "password", "This field cannot be blank")
+
+	if form.Valid() && snippet.HasPassword() {
+		err = snippet.CheckPassword(form.Password)
+		if err != nil {
+			if !errors.Is(err, models.ErrInvalidCredentials) {
+				app.serverError(w, err)
+				return
+			}
+			form.AddNonFieldError("The password is incorrect")
+		}
+	}
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "unlock.tmpl.html", data)
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), unlockedSnippetKey(snippet), true)
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// Maximum length of a search query
+const maxSearchQueryChars = 200
+
+func (app *application) snippetSearch(w http.ResponseWriter, r *http.Request) {
+	// Read the search query and page from the query string
+	// E.g. /snippet/search?q=golang&page=2
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if !validator.MaxChars(query, maxSearchQueryChars) {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	page, err := app.readPage(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Query = query
+
+	// With no query, just show the search form
+	if query != "" {
+		snippets, total, err := app.snippets.Search(query, listPageSize, (page-1)*listPageSize)
+		if err != nil {
+			app.serverError(w, err)
+			return
+		}
+
+		data.Snippets = snippets
+		data.Pagination = newPagination(r, page, listPageSize, total)
+	}
+
+	app.render(w, http.StatusOK, "search.tmpl.html", data)
 }
 
 func (app *application) snippetCreate(w http.ResponseWriter, r *http.Request) {
 	data := app.newTemplateData(r)
 
 	data.Form = snippetCreateForm{
-		Expires: 365,
+		Files:      []snippetFileForm{{Language: highlight.DefaultLanguage}},
+		Visibility: models.VisibilityPublic,
+		Expires:    "365d",
 	}
 
 	app.render(w, http.StatusOK, "create.tmpl.html", data)
 }
 
 func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request) {
-	// Declare empty instance of snippetCreateForm struct
-	var form snippetCreateForm
+	// Snippets are public unless the form says otherwise
+	form := snippetCreateForm{
+		Visibility: models.VisibilityPublic,
+	}
 
 	err := app.decodePostForm(r, &form)
 	if err != nil {
@@ -104,10 +329,7 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
-	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
-	form.CheckField(validator.NotBlank(form.Content), "content", "This field cannot be blank")
-	form.CheckField(validator.PermittedInt(form.Expires, 1, 7, 365), "expires", "This field must equal 1, 7 or 365")
+	form.validate()
 
 	// If errors, re-display create.tmpl.html
 	// Use HTTP status code 422 Unprocessable Entity
@@ -119,8 +341,25 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	forkedFrom, err := app.forkedFromID(r, form.ForkedFrom)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
 	// Pass the data to the SnippetModel.Insert() method
-	id, err := app.snippets.Insert(form.Title, form.Content, form.Expires)
+	// The route is protected, so there is always an authenticated user
+	slug, err := app.snippets.Insert(models.NewSnippet{
+		UserID:           app.authenticatedUserID(r),
+		Title:            form.Title,
+		Files:            snippetFiles(form.Files),
+		Tags:             form.Tags.Normalized(),
+		Visibility:       form.Visibility,
+		Password:         form.Password,
+		BurnAfterReading: form.BurnAfterReading,
+		Expires:          expiryTime(form.Expires, form.ExpiresAt, time.Now()),
+		ForkedFrom:       forkedFrom,
+	})
 	if err != nil {
 		app.serverError(w, err)
 		return
@@ -130,7 +369,244 @@ func (app *application) snippetCreatePost(w http.ResponseWriter, r *http.Request
 	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully created!")
 
 	// Update redirect path to use clean URL format
-	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%d", id), http.StatusSeeOther)
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", slug), http.StatusSeeOther)
+}
+
+// Look up the snippet named by the slug param in the URL
+// Snippets used to be addressed by their numeric ID, so if there is no
+// such slug and the param is a number, that ID is tried instead and legacy
+// is true. Only listed snippets can be found that way, or the IDs could be
+// counted through to find unlisted and burn after reading ones
+// Private snippets of other users are reported as not found, so their
+// existence isn't revealed
+func (app *application) findSnippet(r *http.Request) (snippet *models.Snippet, legacy bool, err error) {
+	param := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+	// Expired snippets are treated as not found by the model
+	snippet, err = app.snippets.GetBySlug(param)
+	if errors.Is(err, models.ErrNoRecord) {
+		id, convErr := strconv.Atoi(param)
+		if convErr != nil || id < 1 {
+			return nil, false, models.ErrNoRecord
+		}
+
+		snippet, err = app.snippets.Get(id)
+		if err == nil && !snippet.Listed() {
+			return nil, false, models.ErrNoRecord
+		}
+		legacy = true
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !snippet.VisibleTo(app.authenticatedUserID(r)) {
+		return nil, false, models.ErrNoRecord
+	}
+
+	return snippet, legacy, nil
+}
+
+// Report whether showing the snippet to the user of r will burn it
+// Burn after reading snippets burn for anyone but their owner
+func (app *application) burnsOnRead(r *http.Request, snippet *models.Snippet) bool {
+	return snippet.BurnAfterReading && snippet.UserID != app.authenticatedUserID(r)
+}
+
+// Delete a burn after reading snippet now that it's being read
+// Owners can look at their own snippet without burning it
+// Returns models.ErrNoRecord if a concurrent request burned it first, in
+// which case the content mustn't be shown
+func (app *application) burnSnippet(r *http.Request, snippet *models.Snippet) (burned bool, err error) {
+	if !app.burnsOnRead(r, snippet) {
+		return false, nil
+	}
+
+	err = app.snippets.Burn(snippet.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// Fetch the snippet with the slug from the URL
+// If there is no such snippet, an error response is sent and ok is false
+// Old numeric URLs are permanently redirected to the slug URL
+func (app *application) snippetFromURL(w http.ResponseWriter, r *http.Request) (snippet *models.Snippet, ok bool) {
+	snippet, legacy, err := app.findSnippet(r)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return nil, false
+	}
+
+	if legacy {
+		// Forms always post to the slug URL, so only links need redirecting
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			app.notFound(w)
+			return nil, false
+		}
+
+		// Swap the ID in the path for the slug, keeping any query string
+		param := httprouter.ParamsFromContext(r.Context()).ByName("slug")
+
+		u := *r.URL
+		segments := strings.Split(u.Path, "/")
+		for i, segment := range segments {
+			if segment == param {
+				segments[i] = snippet.Slug
+				break
+			}
+		}
+		u.Path = strings.Join(segments, "/")
+
+		http.Redirect(w, r, u.RequestURI(), http.StatusMovedPermanently)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Fetch the snippet with the id from the URL and check that it belongs to the
+// authenticated user. If not, an error response is sent and ok is false
+func (app *application) ownedSnippet(w http.ResponseWriter, r *http.Request) (snippet *models.Snippet, ok bool) {
+	snippet, ok = app.snippetFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	// Only the owner is allowed to edit or delete a snippet
+	if snippet.UserID != app.authenticatedUserID(r) {
+		app.clientError(w, http.StatusForbidden)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+// Fetch the snippet with the slug from the URL for a page other than the
+// view page that shows its content, like its history or the fork form
+// Locked snippets ask for the passphrase on the view page first, and burn
+// after reading snippets can only be read on the view page, except by
+// their owner, who can read them without burning them
+func (app *application) readableSnippet(w http.ResponseWriter, r *http.Request) (snippet *models.Snippet, ok bool) {
+	snippet, ok = app.snippetFromURL(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if !app.isUnlocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+		return nil, false
+	}
+
+	if app.burnsOnRead(r, snippet) {
+		app.notFound(w)
+		return nil, false
+	}
+
+	return snippet, true
+}
+
+func (app *application) snippetEdit(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Pre-fill the form with the current snippet
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Form = snippetEditForm{
+		Title:      snippet.Title,
+		Files:      fileForms(snippet.Files),
+		Tags:       newTagList(snippet.Tags),
+		Visibility: snippet.Visibility,
+	}
+
+	app.render(w, http.StatusOK, "edit.tmpl.html", data)
+}
+
+func (app *application) snippetEditPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	// Keep the current visibility if none is submitted
+	// The submitted files replace all of the current ones
+	form := snippetEditForm{
+		Visibility: snippet.Visibility,
+	}
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.Files = cleanFileForms(form.Files)
+
+	form.CheckField(validator.NotBlank(form.Title), "title", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Title, 100), "title", "This field cannot be more than 100 characters long")
+	validateFileForms(&form.Validator, form.Files)
+	validateTags(&form.Validator, form.Tags)
+	form.CheckField(validator.PermittedValue(form.Visibility, models.Visibilities...), "visibility", "This field must equal public, unlisted or private")
+
+	if !form.Valid() {
+		data := app.newTemplateData(r)
+		data.Snippet = snippet
+		data.Form = form
+		app.render(w, http.StatusUnprocessableEntity, "edit.tmpl.html", data)
+		return
+	}
+
+	err = app.snippets.Update(models.SnippetUpdate{
+		ID:         snippet.ID,
+		UserID:     snippet.UserID,
+		Title:      form.Title,
+		Files:      snippetFiles(form.Files),
+		Tags:       form.Tags.Normalized(),
+		Visibility: form.Visibility,
+	})
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully updated!")
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+func (app *application) snippetDeletePost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.ownedSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.snippets.Delete(snippet.ID, snippet.UserID)
+	if err != nil {
+		// The snippet may have been deleted by another request in the meantime
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Snippet successfully deleted!")
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
 func (app *application) userSignup(w http.ResponseWriter, r *http.Request) {
@@ -260,3 +736,74 @@ func (app *application) userLogoutPost(w http.ResponseWriter, r *http.Request) {
 	// Redirect the user to the home page
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
+
+// Render the account page listing the user's API tokens
+// newToken is the plain-text value of a token that was just created, if any
+func (app *application) renderTokens(w http.ResponseWriter, r *http.Request, status int, form tokenCreateForm, newToken string) {
+	tokens, err := app.tokens.List(app.authenticatedUserID(r))
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tokens = tokens
+	data.NewToken = newToken
+	data.Form = form
+
+	app.render(w, status, "tokens.tmpl.html", data)
+}
+
+func (app *application) accountTokens(w http.ResponseWriter, r *http.Request) {
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{}, "")
+}
+
+func (app *application) accountTokensPost(w http.ResponseWriter, r *http.Request) {
+	var form tokenCreateForm
+
+	err := app.decodePostForm(r, &form)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	form.CheckField(validator.NotBlank(form.Name), "name", "This field cannot be blank")
+	form.CheckField(validator.MaxChars(form.Name, 100), "name", "This field cannot be more than 100 characters long")
+
+	if !form.Valid() {
+		app.renderTokens(w, r, http.StatusUnprocessableEntity, form, "")
+		return
+	}
+
+	token, err := app.tokens.Insert(app.authenticatedUserID(r), form.Name)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	// Render the page directly instead of redirecting
+	// The plain-text token is only ever shown this once
+	app.renderTokens(w, r, http.StatusOK, tokenCreateForm{}, token)
+}
+
+func (app *application) accountTokenRevokePost(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFound(w)
+		return
+	}
+
+	err = app.tokens.Delete(id, app.authenticatedUserID(r))
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	app.sessionManager.Put(r.Context(), "flash", "Token successfully revoked!")
+
+	http.Redirect(w, r, "/account/tokens", http.StatusSeeOther)
+}
@@ -0,0 +1,2088 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+func TestUserSignup(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	// Existing user for the duplicate email case
+	err := app.users.Insert("Bob", "dupe@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/signup")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	const (
+		validName     = "Alice"
+		validPassword = "validPa$$word"
+		validEmail    = "alice@example.com"
+		formTag       = `<form action="/user/signup" method="POST" novalidate>`
+	)
+
+	tests := []struct {
+		name         string
+		userName     string
+		userEmail    string
+		userPassword string
+		csrfToken    string
+		wantCode     int
+		wantFormTag  string
+	}{
+		{"Valid submission", validName, validEmail, validPassword, validCSRFToken, http.StatusSeeOther, ""},
+		{"Invalid CSRF Token", validName, "other@example.com", validPassword, "wrongToken", http.StatusBadRequest, ""},
+		{"Empty name", "", "other@example.com", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Empty email", validName, "", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Empty password", validName, "other@example.com", "", validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Invalid email", validName, "bob@example.", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Short password", validName, "other@example.com", "pa$$", validCSRFToken, http.StatusUnprocessableEntity, formTag},
+		{"Duplicate email", validName, "dupe@example.com", validPassword, validCSRFToken, http.StatusUnprocessableEntity, formTag},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("name", tt.userName)
+			form.Add("email", tt.userEmail)
+			form.Add("password", tt.userPassword)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, _, body := ts.postForm(t, "/user/signup", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantFormTag != "" && !strings.Contains(body, tt.wantFormTag) {
+				t.Errorf("want body %q to contain %q", body, tt.wantFormTag)
+			}
+		})
+	}
+}
+
+func TestUserLogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name         string
+		email        string
+		password     string
+		wantCode     int
+		wantLocation string
+		wantBody     string
+	}{
+		{"Valid credentials", "alice@example.com", "pa$$word1", http.StatusSeeOther, "/snippet/create", ""},
+		{"Wrong password", "alice@example.com", "wrongpassword", http.StatusUnprocessableEntity, "", "Email or password is incorrect"},
+		{"Unknown email", "nobody@example.com", "pa$$word1", http.StatusUnprocessableEntity, "", "Email or password is incorrect"},
+		{"Empty password", "alice@example.com", "", http.StatusUnprocessableEntity, "", "This field cannot be blank"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("email", tt.email)
+			form.Add("password", tt.password)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/user/login", form)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if location := header.Get("Location"); location != tt.wantLocation {
+				t.Errorf("want location %q; got %q", tt.wantLocation, location)
+			}
+
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestSnippetCreate(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	t.Run("Unauthenticated", func(t *testing.T) {
+		code, header, _ := ts.get(t, "/snippet/create")
+
+		if code != http.StatusSeeOther {
+			t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		if location := header.Get("Location"); location != "/user/login" {
+			t.Errorf("want location %q; got %q", "/user/login", location)
+		}
+	})
+
+	ts.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	if !strings.Contains(body, `<form action="/snippet/create" method="post">`) {
+		t.Fatal("want body to contain the create form")
+	}
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Invalid submission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "")
+		form.Add("files[0].content", "Some content")
+		form.Add("files[0].language", "klingon")
+		form.Add("expires", "3d")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+		}
+
+		for _, want := range []string{"This field cannot be blank", "This field must be a supported language", "This field must be a supported expiry"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("Valid submission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "An old silent pond")
+		form.Add("files[0].content", "An old silent pond...")
+		form.Add("expires", "7d")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		location := header.Get("Location")
+		if !strings.HasPrefix(location, "/snippet/view/") {
+			t.Fatalf("want location to start with /snippet/view/; got %q", location)
+		}
+
+		// The new snippet shows the flash message and its author
+		code, _, body := ts.get(t, location)
+
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+
+		for _, want := range []string{"Snippet successfully created!", "An old silent pond...", "By Alice", "Plain text"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("Highlighted submission", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "Hello")
+		form.Add("files[0].content", "package main\n\nfunc main() {}")
+		form.Add("files[0].language", "go")
+		form.Add("expires", "7d")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := ts.postForm(t, "/snippet/create", form)
+
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, _, body := ts.get(t, header.Get("Location"))
+
+		for _, want := range []string{`class="chroma"`, `<span class="kn">package</span>`, "Go"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	expiresAt := time.Now().UTC().Add(48 * time.Hour).Truncate(time.Minute)
+
+	tests := []struct {
+		name       string
+		expires    string
+		expiresAt  string
+		wantCode   int
+		wantInBody string
+		wantOnView string
+	}{
+		{
+			name:       "Never expires",
+			expires:    "never",
+			wantCode:   http.StatusSeeOther,
+			wantOnView: "Expires: Never",
+		},
+		{
+			name:       "Custom expiry",
+			expires:    "custom",
+			expiresAt:  expiresAt.Format("2006-01-02T15:04"),
+			wantCode:   http.StatusSeeOther,
+			wantOnView: "Expires: " + humanDate(expiresAt),
+		},
+		{
+			name:       "Custom expiry in the past",
+			expires:    "custom",
+			expiresAt:  "2020-01-01T12:00",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantInBody: "This field must be in the future",
+		},
+		{
+			name:       "Custom expiry not a date",
+			expires:    "custom",
+			expiresAt:  "tomorrow",
+			wantCode:   http.StatusUnprocessableEntity,
+			wantInBody: "This field must be a valid date and time",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Expiring")
+			form.Add("files[0].content", "Some content")
+			form.Add("expires", tt.expires)
+			form.Add("expires_at", tt.expiresAt)
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantInBody != "" && !strings.Contains(body, tt.wantInBody) {
+				t.Errorf("want body to contain %q", tt.wantInBody)
+			}
+
+			if tt.wantOnView != "" {
+				_, _, body := ts.get(t, header.Get("Location"))
+				if !strings.Contains(body, tt.wantOnView) {
+					t.Errorf("want body to contain %q", tt.wantOnView)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetView(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "An old silent pond", Files: oneFile("plaintext", "An old silent pond..."), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+	}{
+		{"Valid slug", "/snippet/view/" + slug, http.StatusOK, "An old silent pond..."},
+		{"Non-existent slug", "/snippet/view/AAAAAAAAAAAAAAAA", http.StatusNotFound, ""},
+		{"Non-existent ID", "/snippet/view/99", http.StatusNotFound, ""},
+		{"Negative ID", "/snippet/view/-1", http.StatusNotFound, ""},
+		{"Decimal ID", "/snippet/view/1.23", http.StatusNotFound, ""},
+		{"String ID", "/snippet/view/foo", http.StatusNotFound, ""},
+		{"Empty ID", "/snippet/view/", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+
+	// Old numeric URLs of public snippets redirect to the slug URL
+	t.Run("Legacy numeric ID", func(t *testing.T) {
+		code, header, _ := ts.get(t, fmt.Sprintf("/snippet/view/%d", snippet.ID))
+
+		if code != http.StatusMovedPermanently {
+			t.Errorf("want %d; got %d", http.StatusMovedPermanently, code)
+		}
+
+		if location := header.Get("Location"); location != "/snippet/view/"+slug {
+			t.Errorf("want location %q; got %q", "/snippet/view/"+slug, location)
+		}
+	})
+}
+
+func TestSnippetOwnership(t *testing.T) {
+	app := newTestApplication(t)
+
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "An old silent pond", Files: oneFile("plaintext", "An old silent pond..."), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := bob.get(t, "/snippet/view/"+slug)
+	validCSRFToken := extractCSRFToken(t, body)
+
+	editForm := url.Values{}
+	editForm.Add("title", "A frog jumps in")
+	editForm.Add("files[0].content", "The sound of water")
+	editForm.Add("csrf_token", validCSRFToken)
+
+	deleteForm := url.Values{}
+	deleteForm.Add("csrf_token", validCSRFToken)
+
+	t.Run("Edit form", func(t *testing.T) {
+		code, _, body := bob.get(t, "/snippet/edit/"+slug)
+
+		if code != http.StatusForbidden {
+			t.Errorf("want %d; got %d", http.StatusForbidden, code)
+		}
+
+		if strings.Contains(body, "An old silent pond...") {
+			t.Error("want body not to contain the edit form")
+		}
+	})
+
+	t.Run("Edit", func(t *testing.T) {
+		code, _, _ := bob.postForm(t, "/snippet/edit/"+slug, editForm)
+
+		if code != http.StatusForbidden {
+			t.Errorf("want %d; got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		code, _, _ := bob.postForm(t, "/snippet/delete/"+slug, deleteForm)
+
+		if code != http.StatusForbidden {
+			t.Errorf("want %d; got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("Unchanged", func(t *testing.T) {
+		snippet, err := app.snippets.GetBySlug(slug)
+		if err != nil {
+			t.Fatalf("want the snippet to still exist; got %v", err)
+		}
+
+		if snippet.Title != "An old silent pond" || snippet.Files[0].Content != "An old silent pond..." {
+			t.Errorf("want the snippet unchanged; got %q %q", snippet.Title, snippet.Files[0].Content)
+		}
+
+		revisions, err := app.snippets.Revisions(snippet.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(revisions) != 1 {
+			t.Errorf("want 1 revision; got %d", len(revisions))
+		}
+
+		code, _, _ := alice.get(t, "/snippet/edit/"+slug)
+		if code != http.StatusOK {
+			t.Errorf("owner: want %d; got %d", http.StatusOK, code)
+		}
+	})
+}
+
+func TestSnippetRaw(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := "func main() {\r\n\tfmt.Println(\"  hi  \")\r\n}"
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Hello, World!", Files: oneFile("go", content), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Raw", func(t *testing.T) {
+		code, header, body := ts.get(t, "/snippet/raw/"+slug)
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if body != content {
+			t.Errorf("want body %q; got %q", content, body)
+		}
+
+		if ct := header.Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+			t.Errorf("want content type %q; got %q", "text/plain; charset=utf-8", ct)
+		}
+
+		if header.Get("ETag") == "" || header.Get("Last-Modified") == "" {
+			t.Error("want ETag and Last-Modified headers")
+		}
+	})
+
+	t.Run("Download", func(t *testing.T) {
+		code, header, body := ts.get(t, "/snippet/download/"+slug)
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if body != content {
+			t.Errorf("want body %q; got %q", content, body)
+		}
+
+		want := `attachment; filename=hello-world.go`
+		if cd := header.Get("Content-Disposition"); cd != want {
+			t.Errorf("want content disposition %q; got %q", want, cd)
+		}
+	})
+
+	t.Run("Not modified", func(t *testing.T) {
+		_, header, _ := ts.get(t, "/snippet/raw/"+slug)
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/snippet/raw/"+slug, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", header.Get("ETag"))
+
+		rs, err := ts.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rs.Body.Close()
+
+		if rs.StatusCode != http.StatusNotModified {
+			t.Errorf("want %d; got %d", http.StatusNotModified, rs.StatusCode)
+		}
+	})
+
+	t.Run("Non-existent ID", func(t *testing.T) {
+		for _, urlPath := range []string{"/snippet/raw/99", "/snippet/download/99"} {
+			code, _, _ := ts.get(t, urlPath)
+
+			if code != http.StatusNotFound {
+				t.Errorf("%s: want %d; got %d", urlPath, http.StatusNotFound, code)
+			}
+		}
+	})
+}
+
+func TestSnippetFiles(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Invalid files", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("title", "Two mains")
+		form.Add("files[0].filename", "main.go")
+		form.Add("files[0].content", "package main")
+		form.Add("files[1].filename", "main.go")
+		form.Add("files[1].content", "package main")
+		form.Add("files[2].filename", "cmd/web.go")
+		form.Add("files[2].content", "package main")
+		form.Add("expires", "7d")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := ts.postForm(t, "/snippet/create", form)
+
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+		}
+
+		for _, want := range []string{"Another file already has this name", "This field cannot contain slashes"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	// The empty block in the middle is left out
+	form := url.Values{}
+	form.Add("title", "Hello")
+	form.Add("files[0].filename", "main.go")
+	form.Add("files[0].language", "go")
+	form.Add("files[0].content", "package main\n\nfunc main() {}")
+	form.Add("files[1].language", "plaintext")
+	form.Add("files[3].filename", "README.md")
+	form.Add("files[3].language", "markdown")
+	form.Add("files[3].content", "# Hello")
+	form.Add("expires", "7d")
+	form.Add("csrf_token", validCSRFToken)
+
+	code, header, _ := ts.postForm(t, "/snippet/create", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+	slug := strings.TrimPrefix(header.Get("Location"), "/snippet/view/")
+
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(snippet.Files) != 2 {
+		t.Fatalf("want 2 files; got %d", len(snippet.Files))
+	}
+
+	t.Run("View", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/view/"+slug)
+
+		for _, want := range []string{"main.go", "README.md", "Markdown", "/snippet/raw/" + slug + "/2", "/snippet/download/" + slug + "/2"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+		wantBody string
+		wantName string
+	}{
+		{"First file", "/snippet/raw/" + slug, http.StatusOK, "package main\n\nfunc main() {}", ""},
+		{"Second file", "/snippet/raw/" + slug + "/2", http.StatusOK, "# Hello", ""},
+		{"Download second file", "/snippet/download/" + slug + "/2", http.StatusOK, "# Hello", "attachment; filename=README.md"},
+		{"Non-existent file", "/snippet/raw/" + slug + "/3", http.StatusNotFound, "", ""},
+		{"Invalid file", "/snippet/raw/" + slug + "/0", http.StatusNotFound, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, header, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if tt.wantBody != "" && body != tt.wantBody {
+				t.Errorf("want body %q; got %q", tt.wantBody, body)
+			}
+
+			if cd := header.Get("Content-Disposition"); cd != tt.wantName {
+				t.Errorf("want content disposition %q; got %q", tt.wantName, cd)
+			}
+		})
+	}
+
+	t.Run("Remove a file", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/edit/"+slug)
+
+		form := url.Values{}
+		form.Add("title", "Hello")
+		form.Add("files[0].filename", "main.go")
+		form.Add("files[0].language", "go")
+		form.Add("files[0].content", "package main\n\nfunc main() {}")
+		form.Add("csrf_token", extractCSRFToken(t, body))
+
+		code, _, _ := ts.postForm(t, "/snippet/edit/"+slug, form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, _, body = ts.get(t, "/snippet/view/"+slug+"/diff?from=1&to=2")
+
+		for _, want := range []string{"<strong>README.md</strong>", "<span>Removed</span>", `<span class="deleted">-# Hello</span>`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+}
+
+func TestSnippetVisibility(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Separate servers so each has its own cookie jar
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	slugs := map[string]string{}
+	for _, visibility := range []string{"public", "unlisted", "private"} {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "A " + visibility + " haiku", Files: oneFile("plaintext", "An old silent pond..."), Visibility: visibility, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs[visibility] = slug
+	}
+
+	// Numeric IDs can be counted through, so they only find public snippets
+	t.Run("Legacy numeric IDs", func(t *testing.T) {
+		for visibility, wantCode := range map[string]int{"public": http.StatusMovedPermanently, "unlisted": http.StatusNotFound} {
+			snippet, err := app.snippets.GetBySlug(slugs[visibility])
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _, _ := anonymous.get(t, fmt.Sprintf("/snippet/view/%d", snippet.ID))
+
+			if code != wantCode {
+				t.Errorf("%s: want %d; got %d", visibility, wantCode, code)
+			}
+		}
+	})
+
+	t.Run("Listings", func(t *testing.T) {
+		for _, urlPath := range []string{"/", "/snippet/search?q=haiku"} {
+			_, _, body := alice.get(t, urlPath)
+
+			if !strings.Contains(body, "A public") {
+				t.Errorf("%s: want body to contain the public snippet", urlPath)
+			}
+
+			for _, hidden := range []string{"A unlisted", "A private"} {
+				if strings.Contains(body, hidden) {
+					t.Errorf("%s: want body not to contain %q", urlPath, hidden)
+				}
+			}
+		}
+	})
+
+	tests := []struct {
+		name       string
+		ts         *testServer
+		visibility string
+		wantCode   int
+	}{
+		{"Anonymous public", anonymous, "public", http.StatusOK},
+		{"Anonymous unlisted", anonymous, "unlisted", http.StatusOK},
+		{"Anonymous private", anonymous, "private", http.StatusNotFound},
+		{"Owner private", alice, "private", http.StatusOK},
+		{"Other user private", bob, "private", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, prefix := range []string{"/snippet/view/", "/snippet/raw/", "/api/v1/snippets/"} {
+				code, _, _ := tt.ts.get(t, prefix+slugs[tt.visibility])
+
+				if code != tt.wantCode {
+					t.Errorf("%s: want %d; got %d", prefix, tt.wantCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestBurnAfterReading(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Secret", Files: oneFile("plaintext", "hunter2"), Visibility: "public", BurnAfterReading: true, Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Not listed", func(t *testing.T) {
+		_, _, body := anonymous.get(t, "/")
+
+		if strings.Contains(body, "Secret") {
+			t.Error("want body not to contain the snippet")
+		}
+	})
+
+	t.Run("Owner view", func(t *testing.T) {
+		code, _, body := alice.get(t, "/snippet/view/"+slug)
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if !strings.Contains(body, "will be deleted the first time someone else views it") {
+			t.Error("want body to contain the burn after reading notice")
+		}
+	})
+
+	t.Run("First view", func(t *testing.T) {
+		code, header, body := anonymous.get(t, "/snippet/view/"+slug)
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		for _, want := range []string{"hunter2", "This snippet has now been deleted"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+
+		if cc := header.Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("want cache control %q; got %q", "no-store", cc)
+		}
+	})
+
+	t.Run("Second view", func(t *testing.T) {
+		code, _, _ := anonymous.get(t, "/snippet/view/"+slug)
+
+		if code != http.StatusNotFound {
+			t.Errorf("want %d; got %d", http.StatusNotFound, code)
+		}
+	})
+
+	// Requests that wouldn't get the content mustn't burn the snippet
+	t.Run("HEAD request", func(t *testing.T) {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Secret", Files: oneFile("plaintext", "hunter2"), Visibility: "public", BurnAfterReading: true, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, tt := range []struct {
+			name     string
+			ts       *testServer
+			wantCode int
+		}{
+			{"Anonymous", anonymous, http.StatusMethodNotAllowed},
+			{"Owner", alice, http.StatusOK},
+		} {
+			rs, err := tt.ts.Client().Head(tt.ts.URL + "/snippet/raw/" + slug)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rs.Body.Close()
+
+			if rs.StatusCode != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, rs.StatusCode)
+			}
+		}
+
+		code, _, body := anonymous.get(t, "/snippet/raw/"+slug)
+		if code != http.StatusOK || body != "hunter2" {
+			t.Errorf("want %d and the content; got %d and %q", http.StatusOK, code, body)
+		}
+	})
+
+	t.Run("Conditional request", func(t *testing.T) {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Secret", Files: oneFile("plaintext", "hunter2"), Visibility: "public", BurnAfterReading: true, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, anonymous.URL+"/snippet/raw/"+slug, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("If-None-Match", "*")
+		req.Header.Set("If-Modified-Since", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		req.Header.Set("Range", "bytes=0-1")
+
+		rs, err := anonymous.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rs.Body.Close()
+
+		body, err := io.ReadAll(rs.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The only read gets all of it
+		if rs.StatusCode != http.StatusOK || string(body) != "hunter2" {
+			t.Errorf("want %d and the content; got %d and %q", http.StatusOK, rs.StatusCode, body)
+		}
+
+		if etag := rs.Header.Get("ETag"); etag != "" {
+			t.Errorf("want no ETag; got %q", etag)
+		}
+	})
+
+	// Only one of many simultaneous readers gets to see the content
+	t.Run("Concurrent views", func(t *testing.T) {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Secret", Files: oneFile("plaintext", "hunter2"), Visibility: "public", BurnAfterReading: true, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		var ok int32
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				rs, err := anonymous.Client().Get(anonymous.URL + "/snippet/raw/" + slug)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				rs.Body.Close()
+
+				if rs.StatusCode == http.StatusOK {
+					atomic.AddInt32(&ok, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if n := atomic.LoadInt32(&ok); n != 1 {
+			t.Errorf("want 1 successful read; got %d", n)
+		}
+	})
+}
+
+func TestSnippetPassword(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Locked", Files: oneFile("plaintext", "hunter2"), Visibility: "public", Password: "open sesame", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Owner view", func(t *testing.T) {
+		_, _, body := alice.get(t, "/snippet/view/"+slug)
+
+		if !strings.Contains(body, "hunter2") {
+			t.Error("want body to contain the content")
+		}
+	})
+
+	t.Run("Not listed", func(t *testing.T) {
+		_, _, body := anonymous.get(t, "/")
+
+		if strings.Contains(body, "Locked") {
+			t.Error("want body not to contain the snippet")
+		}
+	})
+
+	_, _, body := anonymous.get(t, "/snippet/view/"+slug)
+	if strings.Contains(body, "hunter2") || !strings.Contains(body, "protected by a password") {
+		t.Fatal("want body to contain the unlock form and not the content")
+	}
+	validCSRFToken := extractCSRFToken(t, body)
+
+	t.Run("Locked raw", func(t *testing.T) {
+		code, header, _ := anonymous.get(t, "/snippet/raw/"+slug)
+
+		if code != http.StatusSeeOther {
+			t.Errorf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		if location := header.Get("Location"); location != "/snippet/view/"+slug {
+			t.Errorf("want location %q; got %q", "/snippet/view/"+slug, location)
+		}
+	})
+
+	t.Run("Wrong password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "open sesame!")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, body := anonymous.postForm(t, "/snippet/unlock/"+slug, form)
+
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+		}
+
+		if !strings.Contains(body, "The password is incorrect") {
+			t.Error("want body to contain the error message")
+		}
+	})
+
+	t.Run("Right password", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("password", "open sesame")
+		form.Add("csrf_token", validCSRFToken)
+
+		code, _, _ := anonymous.postForm(t, "/snippet/unlock/"+slug, form)
+
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		// The unlock is remembered in the session
+		for _, urlPath := range []string{"/snippet/view/" + slug, "/snippet/raw/" + slug} {
+			code, _, body := anonymous.get(t, urlPath)
+
+			if code != http.StatusOK {
+				t.Errorf("%s: want %d; got %d", urlPath, http.StatusOK, code)
+			}
+
+			if !strings.Contains(body, "hunter2") {
+				t.Errorf("%s: want body to contain the content", urlPath)
+			}
+		}
+	})
+}
+
+func TestSnippetHistory(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Haiku", Files: oneFile("plaintext", "An old silent pond\nA frog jumps into the pond\nsplash! Silence again.\n"), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Edit the snippet through the form to make a second revision
+	_, _, body := alice.get(t, "/snippet/edit/"+slug)
+
+	form := url.Values{}
+	form.Add("title", "Frog haiku")
+	form.Add("files[0].content", "An old silent pond\r\nA frog leaps into the pond\r\nsplash! Silence again.\r\n")
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := alice.postForm(t, "/snippet/edit/"+slug, form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+	}
+
+	t.Run("History", func(t *testing.T) {
+		code, _, body := anonymous.get(t, "/snippet/view/"+slug+"/history")
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		for _, want := range []string{"<td>Haiku</td>", "<td>Frog haiku</td>", "<td>Alice</td>", "/diff?from=1&amp;to=2"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		code, _, body := anonymous.get(t, "/snippet/view/"+slug+"/diff?from=1&to=2")
+
+		if code != http.StatusOK {
+			t.Fatalf("want %d; got %d", http.StatusOK, code)
+		}
+
+		// Only the changed line differs, not the line endings
+		// html/template escapes the + signs, so compare the unescaped text
+		body = html.UnescapeString(body)
+
+		for _, want := range []string{
+			"@@ -1,3 +1,3 @@",
+			`<span class="unchanged"> An old silent pond</span>`,
+			`<span class="deleted">-A frog jumps into the pond</span>`,
+			`<span class="inserted">+A frog leaps into the pond</span>`,
+			"Title changed from <strong>Haiku</strong> to <strong>Frog haiku</strong>",
+		} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	t.Run("Legacy URL", func(t *testing.T) {
+		urlPath := fmt.Sprintf("/snippet/view/%d/diff?from=1&to=2", snippet.ID)
+
+		code, header, _ := anonymous.get(t, urlPath)
+
+		if code != http.StatusMovedPermanently {
+			t.Errorf("want %d; got %d", http.StatusMovedPermanently, code)
+		}
+
+		want := "/snippet/view/" + slug + "/diff?from=1&to=2"
+		if location := header.Get("Location"); location != want {
+			t.Errorf("want location %q; got %q", want, location)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		wantCode int
+	}{
+		{"Missing revisions", "/snippet/view/" + slug + "/diff", http.StatusBadRequest},
+		{"Invalid revision", "/snippet/view/" + slug + "/diff?from=one&to=2", http.StatusBadRequest},
+		{"Non-existent revision", "/snippet/view/" + slug + "/diff?from=1&to=3", http.StatusNotFound},
+		{"Non-existent snippet", "/snippet/view/AAAAAAAAAAAAAAAA/history", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, _ := anonymous.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+		})
+	}
+
+	t.Run("Private snippet", func(t *testing.T) {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Mine", Files: oneFile("plaintext", "Mine alone"), Visibility: "private", Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, _, _ := anonymous.get(t, "/snippet/view/"+slug+"/history")
+		if code != http.StatusNotFound {
+			t.Errorf("want %d; got %d", http.StatusNotFound, code)
+		}
+
+		code, _, _ = alice.get(t, "/snippet/view/"+slug+"/history")
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+	})
+}
+
+func TestSnippetFork(t *testing.T) {
+	app := newTestApplication(t)
+
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	public, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Useful", Files: oneFile("go", "func useful() {}"), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	original, err := app.snippets.GetBySlug(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	private, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Mine", Files: oneFile("plaintext", "Mine alone"), Visibility: "private", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Anonymous", func(t *testing.T) {
+		code, header, _ := anonymous.get(t, "/snippet/fork/"+public)
+
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+			t.Errorf("want redirect to /user/login; got %d %q", code, header.Get("Location"))
+		}
+	})
+
+	t.Run("Private snippet", func(t *testing.T) {
+		code, _, _ := bob.get(t, "/snippet/fork/"+private)
+
+		if code != http.StatusNotFound {
+			t.Errorf("want %d; got %d", http.StatusNotFound, code)
+		}
+	})
+
+	code, _, body := bob.get(t, "/snippet/fork/"+public)
+	if code != http.StatusOK {
+		t.Fatalf("want %d; got %d", http.StatusOK, code)
+	}
+
+	// The create form is filled in with the original
+	for _, want := range []string{`name="forked_from" value="` + public + `"`, `value="Useful"`, "func useful() {}"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+	validCSRFToken := extractCSRFToken(t, body)
+
+	fork := func(t *testing.T, forkedFrom string) string {
+		form := url.Values{}
+		form.Add("title", "Useful")
+		form.Add("files[0].content", "func useful() { return }")
+		form.Add("files[0].language", "go")
+		form.Add("expires", "7d")
+		form.Add("forked_from", forkedFrom)
+		form.Add("csrf_token", validCSRFToken)
+
+		code, header, _ := bob.postForm(t, "/snippet/create", form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		return header.Get("Location")
+	}
+
+	t.Run("Fork", func(t *testing.T) {
+		location := fork(t, public)
+
+		_, _, body := anonymous.get(t, location)
+
+		want := fmt.Sprintf(`Forked from <a href="/snippet/view/%s">#%d</a>`, public, original.ID)
+		if !strings.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+
+		_, _, body = anonymous.get(t, "/snippet/view/"+public)
+
+		if !strings.Contains(body, "1 fork") {
+			t.Errorf("want body to contain %q", "1 fork")
+		}
+	})
+
+	t.Run("Unreadable original", func(t *testing.T) {
+		location := fork(t, private)
+
+		_, _, body := bob.get(t, location)
+
+		if strings.Contains(body, "Forked from") {
+			t.Error("want body not to contain the fork reference")
+		}
+	})
+}
+
+func TestHome(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= 12; i++ {
+		_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: fmt.Sprintf("Snippet number %d", i), Files: oneFile("plaintext", "Content"), Visibility: "public", Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		notWantBody []string
+	}{
+		{
+			"First page",
+			"/",
+			http.StatusOK,
+			[]string{"12 snippets", "Snippet number 12<", "Snippet number 3<", "Page 1 of 2", `href="/?page=2"`},
+			[]string{"Snippet number 2<", "Previous"},
+		},
+		{
+			"Second page",
+			"/?page=2",
+			http.StatusOK,
+			[]string{"Snippet number 2<", "Snippet number 1<", "Page 2 of 2", `href="/"`},
+			[]string{"Snippet number 3<", "Next"},
+		},
+		{"Invalid page", "/?page=0", http.StatusBadRequest, nil, nil},
+		{"String page", "/?page=foo", http.StatusBadRequest, nil, nil},
+		{"Huge page", "/?page=9223372036854775807", http.StatusBadRequest, nil, nil},
+		{"Last possible page", fmt.Sprintf("/?page=%d", maxPage), http.StatusOK, nil, []string{"Snippet number"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+
+			for _, notWant := range tt.notWantBody {
+				if strings.Contains(body, notWant) {
+					t.Errorf("want body not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetSearch(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	err := app.users.Insert("Alice", "alice@example.com", "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippets := []struct{ title, content string }{
+		{"Deploy script", "kubectl apply -f deploy.yaml"},
+		{"Golang <generics>", "func Map[T any](s []T) {}"},
+		{"Shell tricks", "Not about golang at all, but mentions it"},
+	}
+	for _, s := range snippets {
+		_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: s.title, Files: oneFile("plaintext", s.content), Visibility: "public", Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("Ranked and highlighted", func(t *testing.T) {
+		code, _, body := ts.get(t, "/snippet/search?q=GOLANG")
+
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+
+		if !strings.Contains(body, "2 results") {
+			t.Error("want body to contain 2 results")
+		}
+
+		// A title match ranks above a content match, and the title is
+		// still escaped around the highlight
+		title := strings.Index(body, "<mark>Golang</mark> &lt;generics&gt;")
+		content := strings.Index(body, "about <mark>golang</mark> at all")
+		if title == -1 || content == -1 || title > content {
+			t.Errorf("want title match before content match; got %d and %d", title, content)
+		}
+
+		if strings.Contains(body, "Deploy script") {
+			t.Error("want body not to contain non-matching snippet")
+		}
+	})
+
+	t.Run("No results", func(t *testing.T) {
+		_, _, body := ts.get(t, "/snippet/search?q=python")
+
+		if !strings.Contains(body, "No snippets match") {
+			t.Error("want body to contain no results message")
+		}
+	})
+
+	t.Run("Query too long", func(t *testing.T) {
+		code, _, _ := ts.get(t, "/snippet/search?q="+strings.Repeat("a", 201))
+
+		if code != http.StatusBadRequest {
+			t.Errorf("want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+}
+
+func TestSnippetTags(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+
+	ts.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+
+	_, _, body := ts.get(t, "/snippet/create")
+	validCSRFToken := extractCSRFToken(t, body)
+
+	tests := []struct {
+		name       string
+		tags       string
+		wantCode   int
+		wantInBody string
+	}{
+		{"Invalid tag", "go, http/2", http.StatusUnprocessableEntity, "Tags can only use a-z, 0-9"},
+		{"Too many tags", "a b c d e f g h i j k", http.StatusUnprocessableEntity, "A snippet can&#39;t have more than 10 tags"},
+		{"Normalized tags", "Go, #HTTP go  c#", http.StatusSeeOther, ""},
+	}
+
+	var location string
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			form.Add("title", "Tagged")
+			form.Add("files[0].content", "Some content")
+			form.Add("tags", tt.tags)
+			form.Add("expires", "7d")
+			form.Add("csrf_token", validCSRFToken)
+
+			code, header, body := ts.postForm(t, "/snippet/create", form)
+
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !strings.Contains(body, tt.wantInBody) {
+				t.Errorf("want body to contain %q", tt.wantInBody)
+			}
+
+			location = header.Get("Location")
+		})
+	}
+
+	t.Run("View", func(t *testing.T) {
+		_, _, body := ts.get(t, location)
+
+		for _, want := range []string{`<a href="/tag/c%23">#c#</a>`, `<a href="/tag/go">#go</a>`, `<a href="/tag/http">#http</a>`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+	})
+
+	// Fill a second page of the tag, and add snippets that mustn't be listed
+	for i := 1; i <= 10; i++ {
+		_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: fmt.Sprintf("Go number %d", i), Files: oneFile("plaintext", "Content"), Tags: []string{"go"}, Visibility: "public", Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Private go", Files: oneFile("plaintext", "Content"), Tags: []string{"go"}, Visibility: "private", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Expired go", Files: oneFile("plaintext", "Content"), Tags: []string{"go"}, Visibility: "public", Expires: inDays(-1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listings := []struct {
+		name        string
+		urlPath     string
+		wantCode    int
+		wantBody    []string
+		notWantBody []string
+	}{
+		{"First page", "/tag/go", http.StatusOK, []string{"11 snippets", "Go number 10<", "Page 1 of 2"}, []string{"Private go", "Expired go", ">Tagged<"}},
+		{"Second page", "/tag/GO?page=2", http.StatusOK, []string{">Tagged<", "Page 2 of 2"}, []string{"Go number 10<"}},
+		{"Escaped tag", "/tag/c%23", http.StatusOK, []string{"1 snippets", ">Tagged<"}, nil},
+		{"Unused tag", "/tag/rust", http.StatusOK, []string{"There are no snippets tagged #rust."}, nil},
+		{"Invalid tag", "/tag/-go", http.StatusNotFound, nil, nil},
+		{"Invalid page", "/tag/go?page=0", http.StatusBadRequest, nil, nil},
+	}
+
+	for _, tt := range listings {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, body := ts.get(t, tt.urlPath)
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			for _, want := range tt.wantBody {
+				if !strings.Contains(body, want) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+
+			for _, notWant := range tt.notWantBody {
+				if strings.Contains(body, notWant) {
+					t.Errorf("want body not to contain %q", notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestCollections(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Separate servers so each has its own cookie jar
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	_, _, body := alice.get(t, "/collections")
+	aliceCSRFToken := extractCSRFToken(t, body)
+
+	_, _, body = bob.get(t, "/collections")
+	bobCSRFToken := extractCSRFToken(t, body)
+
+	var collectionPath string
+
+	t.Run("Create", func(t *testing.T) {
+		tests := []struct {
+			name       string
+			formName   string
+			visibility string
+			wantCode   int
+			wantInBody string
+		}{
+			{"Blank name", "", "public", http.StatusUnprocessableEntity, "This field cannot be blank"},
+			{"Invalid visibility", "Deploys", "hidden", http.StatusUnprocessableEntity, "This field must equal public, unlisted or private"},
+			{"Valid", "Deploys", "public", http.StatusSeeOther, ""},
+		}
+
+		for _, tt := range tests {
+			form := url.Values{}
+			form.Add("name", tt.formName)
+			form.Add("description", "Scripts for deploying")
+			form.Add("visibility", tt.visibility)
+			form.Add("csrf_token", aliceCSRFToken)
+
+			code, header, body := alice.postForm(t, "/collections", form)
+
+			if code != tt.wantCode {
+				t.Fatalf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+			}
+
+			if !strings.Contains(body, tt.wantInBody) {
+				t.Errorf("%s: want body to contain %q", tt.name, tt.wantInBody)
+			}
+
+			collectionPath = header.Get("Location")
+		}
+
+		_, _, body := alice.get(t, "/collections")
+		if !strings.Contains(body, `<a href="`+collectionPath+`">Deploys</a>`) {
+			t.Errorf("want the list to link to %s", collectionPath)
+		}
+	})
+
+	collectionSlug := strings.TrimPrefix(collectionPath, "/collection/")
+
+	slugs := map[string]string{}
+	for _, s := range []struct {
+		title            string
+		userID           int
+		visibility       string
+		burnAfterReading bool
+	}{
+		{"First script", 1, "public", false},
+		{"Second script", 1, "private", false},
+		{"Third script", 1, "public", true},
+		{"Bob's script", 2, "public", false},
+	} {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: s.userID, Title: s.title, Files: oneFile("bash", "make deploy"), Visibility: s.visibility, BurnAfterReading: s.burnAfterReading, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs[s.title] = slug
+	}
+
+	t.Run("Add snippets", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			ts        *testServer
+			csrfToken string
+			snippet   string
+			wantCode  int
+			wantFlash string
+		}{
+			{"First", alice, aliceCSRFToken, "First script", http.StatusSeeOther, "Snippet added to Deploys!"},
+			{"Second", alice, aliceCSRFToken, "Second script", http.StatusSeeOther, "Snippet added to Deploys!"},
+			{"Third", alice, aliceCSRFToken, "Third script", http.StatusSeeOther, "Snippet added to Deploys!"},
+			{"Already added", alice, aliceCSRFToken, "First script", http.StatusSeeOther, "This snippet is already in Deploys."},
+			{"Other user's snippet", alice, aliceCSRFToken, "Bob's script", http.StatusForbidden, ""},
+			{"Other user's collection", bob, bobCSRFToken, "Bob's script", http.StatusForbidden, ""},
+		}
+
+		for _, tt := range tests {
+			form := url.Values{}
+			form.Add("collection", collectionSlug)
+			form.Add("csrf_token", tt.csrfToken)
+
+			code, header, _ := tt.ts.postForm(t, "/snippet/collect/"+slugs[tt.snippet], form)
+
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+				continue
+			}
+
+			if tt.wantFlash != "" {
+				_, _, body := tt.ts.get(t, header.Get("Location"))
+				if !strings.Contains(body, html.EscapeString(tt.wantFlash)) {
+					t.Errorf("%s: want body to contain %q", tt.name, tt.wantFlash)
+				}
+			}
+		}
+	})
+
+	// Return the titles on the collection page, in order
+	titles := func(ts *testServer) []string {
+		_, _, body := ts.get(t, collectionPath)
+
+		found := []string{}
+		for _, title := range strings.Split(body, `">`) {
+			title, _, _ = strings.Cut(title, "</a>")
+			if strings.HasSuffix(title, " script") && !strings.Contains(title, "<") {
+				found = append(found, title)
+			}
+		}
+		return found
+	}
+
+	t.Run("Visibility of snippets", func(t *testing.T) {
+		if got, want := strings.Join(titles(alice), ", "), "First script, Second script, Third script"; got != want {
+			t.Errorf("owner: want %q; got %q", want, got)
+		}
+
+		if got, want := strings.Join(titles(anonymous), ", "), "First script"; got != want {
+			t.Errorf("anonymous: want %q; got %q", want, got)
+		}
+	})
+
+	t.Run("Move", func(t *testing.T) {
+		for _, move := range []struct{ snippet, direction string }{
+			{"Third script", "up"},
+			{"First script", "up"},
+			{"First script", "down"},
+		} {
+			form := url.Values{}
+			form.Add("snippet", slugs[move.snippet])
+			form.Add("direction", move.direction)
+			form.Add("csrf_token", aliceCSRFToken)
+
+			code, _, _ := alice.postForm(t, collectionPath+"/move", form)
+			if code != http.StatusSeeOther {
+				t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+			}
+		}
+
+		if got, want := strings.Join(titles(alice), ", "), "Third script, First script, Second script"; got != want {
+			t.Errorf("want %q; got %q", want, got)
+		}
+
+		form := url.Values{}
+		form.Add("snippet", slugs["First script"])
+		form.Add("direction", "up")
+		form.Add("csrf_token", bobCSRFToken)
+
+		code, _, _ := bob.postForm(t, collectionPath+"/move", form)
+		if code != http.StatusForbidden {
+			t.Errorf("other user: want %d; got %d", http.StatusForbidden, code)
+		}
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("snippet", slugs["First script"])
+		form.Add("csrf_token", aliceCSRFToken)
+
+		code, _, _ := alice.postForm(t, collectionPath+"/remove", form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		if got, want := strings.Join(titles(alice), ", "), "Third script, Second script"; got != want {
+			t.Errorf("want %q; got %q", want, got)
+		}
+
+		// The snippet itself is kept
+		code, _, _ = alice.get(t, "/snippet/view/"+slugs["First script"])
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+
+		code, _, _ = alice.postForm(t, collectionPath+"/remove", form)
+		if code != http.StatusNotFound {
+			t.Errorf("removed twice: want %d; got %d", http.StatusNotFound, code)
+		}
+	})
+
+	t.Run("Private collection", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("name", "Deploys")
+		form.Add("description", "")
+		form.Add("visibility", "private")
+		form.Add("csrf_token", aliceCSRFToken)
+
+		code, _, _ := alice.postForm(t, collectionPath+"/edit", form)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		for name, tt := range map[string]struct {
+			ts       *testServer
+			wantCode int
+		}{
+			"Owner":      {alice, http.StatusOK},
+			"Other user": {bob, http.StatusNotFound},
+			"Anonymous":  {anonymous, http.StatusNotFound},
+		} {
+			code, _, _ := tt.ts.get(t, collectionPath)
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", name, tt.wantCode, code)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		form := url.Values{}
+		form.Add("csrf_token", aliceCSRFToken)
+
+		code, header, _ := alice.postForm(t, collectionPath+"/delete", form)
+		if code != http.StatusSeeOther || header.Get("Location") != "/collections" {
+			t.Fatalf("want %d to /collections; got %d to %q", http.StatusSeeOther, code, header.Get("Location"))
+		}
+
+		code, _, _ = alice.get(t, collectionPath)
+		if code != http.StatusNotFound {
+			t.Errorf("want %d; got %d", http.StatusNotFound, code)
+		}
+
+		// Its snippets are kept
+		code, _, _ = alice.get(t, "/snippet/view/"+slugs["Second script"])
+		if code != http.StatusOK {
+			t.Errorf("want %d; got %d", http.StatusOK, code)
+		}
+	})
+}
+
+func TestSnippetStars(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Separate servers so each has its own cookie jar
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	slugs := map[string]string{}
+	for _, s := range []struct{ title, visibility string }{
+		{"Older snippet", "public"},
+		{"Newer snippet", "public"},
+		{"Private snippet", "private"},
+	} {
+		slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: s.title, Files: oneFile("plaintext", "Content"), Visibility: s.visibility, Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs[s.title] = slug
+	}
+
+	csrfTokens := map[*testServer]string{}
+	for _, ts := range []*testServer{anonymous, alice, bob} {
+		_, _, body := ts.get(t, "/user/login")
+		csrfTokens[ts] = extractCSRFToken(t, body)
+	}
+
+	star := func(ts *testServer, action, title string) (int, http.Header) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfTokens[ts])
+
+		code, header, _ := ts.postForm(t, "/snippet/"+action+"/"+slugs[title], form)
+		return code, header
+	}
+
+	t.Run("Star", func(t *testing.T) {
+		tests := []struct {
+			name         string
+			ts           *testServer
+			title        string
+			wantCode     int
+			wantLocation string
+		}{
+			{"Anonymous", anonymous, "Older snippet", http.StatusSeeOther, "/user/login"},
+			{"Other user's private snippet", bob, "Private snippet", http.StatusNotFound, ""},
+			{"Bob", bob, "Older snippet", http.StatusSeeOther, "/snippet/view/" + slugs["Older snippet"]},
+			{"Bob again", bob, "Older snippet", http.StatusSeeOther, "/snippet/view/" + slugs["Older snippet"]},
+			{"Alice", alice, "Older snippet", http.StatusSeeOther, "/snippet/view/" + slugs["Older snippet"]},
+			{"Own private snippet", alice, "Private snippet", http.StatusSeeOther, "/snippet/view/" + slugs["Private snippet"]},
+		}
+
+		for _, tt := range tests {
+			code, header := star(tt.ts, "star", tt.title)
+
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+			}
+
+			if got := header.Get("Location"); got != tt.wantLocation {
+				t.Errorf("%s: want location %q; got %q", tt.name, tt.wantLocation, got)
+			}
+		}
+	})
+
+	t.Run("View", func(t *testing.T) {
+		_, _, body := bob.get(t, "/snippet/view/"+slugs["Older snippet"])
+		for _, want := range []string{"2 stars", `action="/snippet/unstar/` + slugs["Older snippet"] + `"`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+
+		_, _, body = bob.get(t, "/snippet/view/"+slugs["Newer snippet"])
+		if !strings.Contains(body, `action="/snippet/star/`+slugs["Newer snippet"]+`"`) {
+			t.Errorf("want a star button")
+		}
+
+		_, _, body = anonymous.get(t, "/snippet/view/"+slugs["Older snippet"])
+		if strings.Contains(body, "/snippet/star/") || strings.Contains(body, "/snippet/unstar/") {
+			t.Errorf("want no star button for anonymous users")
+		}
+	})
+
+	t.Run("Home", func(t *testing.T) {
+		_, _, body := anonymous.get(t, "/")
+		if strings.Index(body, "Newer snippet") > strings.Index(body, "Older snippet") {
+			t.Errorf("want the newest snippet first")
+		}
+
+		_, _, body = anonymous.get(t, "/?sort=stars")
+		if !strings.Contains(body, "Most Starred Snippets") {
+			t.Errorf("want the most starred heading")
+		}
+		if strings.Index(body, "Older snippet") > strings.Index(body, "Newer snippet") {
+			t.Errorf("want the most starred snippet first")
+		}
+		if !strings.Contains(body, "<td>2</td>") {
+			t.Errorf("want the star count listed")
+		}
+
+		code, _, _ := anonymous.get(t, "/?sort=bogus")
+		if code != http.StatusBadRequest {
+			t.Errorf("unknown sort: want %d; got %d", http.StatusBadRequest, code)
+		}
+	})
+
+	t.Run("Starred page", func(t *testing.T) {
+		code, header, _ := anonymous.get(t, "/user/starred")
+		if code != http.StatusSeeOther || header.Get("Location") != "/user/login" {
+			t.Errorf("anonymous: want %d to /user/login; got %d to %q", http.StatusSeeOther, code, header.Get("Location"))
+		}
+
+		_, _, body := alice.get(t, "/user/starred")
+		for _, want := range []string{"2 snippets", "Older snippet", "Private snippet"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+
+		// Snippets made private since are left out for other users
+		star(bob, "star", "Newer snippet")
+
+		snippet, err := app.snippets.GetBySlug(slugs["Newer snippet"])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = app.snippets.Update(models.SnippetUpdate{ID: snippet.ID, UserID: 1, Title: snippet.Title, Files: snippet.Files, Visibility: "private"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, _, body = bob.get(t, "/user/starred")
+		if !strings.Contains(body, "1 snippets") || strings.Contains(body, "Newer snippet") {
+			t.Errorf("want only the public starred snippet")
+		}
+	})
+
+	t.Run("Unstar", func(t *testing.T) {
+		code, _ := star(bob, "unstar", "Older snippet")
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, _, body := bob.get(t, "/user/starred")
+		if !strings.Contains(body, "You haven't starred any snippets yet.") {
+			t.Errorf("want no starred snippets")
+		}
+
+		_, _, body = anonymous.get(t, "/snippet/view/"+slugs["Older snippet"])
+		if !strings.Contains(body, "1 star<") {
+			t.Errorf("want 1 star")
+		}
+	})
+}
+
+func TestSnippetComments(t *testing.T) {
+	app := newTestApplication(t)
+
+	// Separate servers so each has its own cookie jar
+	anonymous := newTestServer(t, app.routes())
+	alice := newTestServer(t, app.routes())
+	bob := newTestServer(t, app.routes())
+
+	alice.login(t, app, "Alice", "alice@example.com", "pa$$word1")
+	bob.login(t, app, "Bob", "bob@example.com", "pa$$word1")
+
+	files := []*models.SnippetFile{
+		{Filename: "main.go", Language: "go", Content: "package main\n\nfunc main() {}\n"},
+		{Filename: "README", Language: "plaintext", Content: "Hello"},
+	}
+
+	slug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Review me", Files: files, Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	privateSlug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Private", Files: oneFile("plaintext", "Secret"), Visibility: "private", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	snippet, err := app.snippets.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	csrfTokens := map[*testServer]string{}
+	for _, ts := range []*testServer{anonymous, alice, bob} {
+		_, _, body := ts.get(t, "/user/login")
+		csrfTokens[ts] = extractCSRFToken(t, body)
+	}
+
+	post := func(ts *testServer, path string, fields map[string]string) (int, http.Header, string) {
+		form := url.Values{}
+		form.Add("csrf_token", csrfTokens[ts])
+		for k, v := range fields {
+			form.Add(k, v)
+		}
+
+		return ts.postForm(t, path, form)
+	}
+
+	// Return the IDs of the snippet's comments, depth first
+	commentIDs := func() []int {
+		threads, err := app.comments.ForSnippet(snippet.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var ids []int
+		var walk func([]*models.Comment)
+		walk = func(comments []*models.Comment) {
+			for _, c := range comments {
+				ids = append(ids, c.ID)
+				walk(c.Replies)
+			}
+		}
+		walk(threads)
+
+		return ids
+	}
+
+	t.Run("Post", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			ts       *testServer
+			slug     string
+			fields   map[string]string
+			wantCode int
+			wantBody string
+		}{
+			{"Anonymous", anonymous, slug, map[string]string{"content": "Hi"}, http.StatusSeeOther, ""},
+			{"Other user's private snippet", bob, privateSlug, map[string]string{"content": "Hi"}, http.StatusNotFound, ""},
+			{"Blank content", bob, slug, map[string]string{"content": " ", "line": ""}, http.StatusUnprocessableEntity, "This field cannot be blank"},
+			{"Line out of range", bob, slug, map[string]string{"content": "Hi", "file": "1", "line": "4"}, http.StatusUnprocessableEntity, "This field must be a line number from 1 to 3"},
+			{"Unknown file", bob, slug, map[string]string{"content": "Hi", "file": "3", "line": "1"}, http.StatusUnprocessableEntity, "This field must be one of the snippet&#39;s files"},
+			{"Unknown parent", bob, slug, map[string]string{"content": "Hi", "parent": "999"}, http.StatusUnprocessableEntity, "The comment you&#39;re replying to has been deleted"},
+			{"Unanchored", bob, slug, map[string]string{"content": "Looks good", "file": "1", "line": ""}, http.StatusSeeOther, ""},
+			{"Anchored", bob, slug, map[string]string{"content": "Why empty?", "file": "1", "line": "3"}, http.StatusSeeOther, ""},
+		}
+
+		for _, tt := range tests {
+			code, _, body := post(tt.ts, "/snippet/comment/"+tt.slug, tt.fields)
+
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+			}
+
+			if !strings.Contains(body, tt.wantBody) {
+				t.Errorf("%s: want body to contain %q", tt.name, tt.wantBody)
+			}
+		}
+
+		ids := commentIDs()
+		if len(ids) != 2 {
+			t.Fatalf("want 2 comments; got %d", len(ids))
+		}
+
+		// Alice replies to Bob's first comment
+		code, header, _ := post(alice, "/snippet/comment/"+slug, map[string]string{"content": "Thanks!", "parent": strconv.Itoa(ids[0])})
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		ids = commentIDs()
+		if len(ids) != 3 {
+			t.Fatalf("want 3 comments; got %d", len(ids))
+		}
+
+		want := fmt.Sprintf("/snippet/view/%s#comment-%d", slug, ids[1])
+		if got := header.Get("Location"); got != want {
+			t.Errorf("want location %q; got %q", want, got)
+		}
+	})
+
+	ids := commentIDs()
+
+	t.Run("View", func(t *testing.T) {
+		_, _, body := anonymous.get(t, "/snippet/view/"+slug)
+
+		for _, want := range []string{"Looks good", "Thanks!", `id="f1-L3"`, `href="#f1-L3"`, "On main.go line 3", `<div class="replies">`} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+
+		if strings.Contains(body, "/comment/delete/") || strings.Contains(body, `action="/snippet/comment/`) {
+			t.Errorf("want no comment forms for anonymous users")
+		}
+
+		// Bob can only delete his own comments, Alice owns the snippet
+		_, _, body = bob.get(t, "/snippet/view/"+slug)
+		if !strings.Contains(body, fmt.Sprintf("/comment/delete/%d", ids[0])) || strings.Contains(body, fmt.Sprintf("/comment/delete/%d", ids[1])) {
+			t.Errorf("want delete buttons on Bob's comments only")
+		}
+
+		_, _, body = alice.get(t, "/snippet/view/"+slug)
+		for _, id := range ids {
+			if !strings.Contains(body, fmt.Sprintf("/comment/delete/%d", id)) {
+				t.Errorf("want a delete button on comment %d", id)
+			}
+		}
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			ts       *testServer
+			id       int
+			wantCode int
+		}{
+			{"Anonymous", anonymous, ids[0], http.StatusSeeOther},
+			{"Not author or owner", bob, ids[1], http.StatusForbidden},
+			{"Snippet owner", alice, ids[0], http.StatusSeeOther},
+			{"Author", bob, ids[2], http.StatusSeeOther},
+			{"Already deleted", bob, ids[2], http.StatusNotFound},
+			{"Already deleted with replies", alice, ids[0], http.StatusNotFound},
+		}
+
+		for _, tt := range tests {
+			code, _, _ := post(tt.ts, fmt.Sprintf("/comment/delete/%d", tt.id), nil)
+
+			if code != tt.wantCode {
+				t.Errorf("%s: want %d; got %d", tt.name, tt.wantCode, code)
+			}
+		}
+
+		// The first comment keeps its place for Alice's reply
+		_, _, body := anonymous.get(t, "/snippet/view/"+slug)
+		for _, want := range []string{"Deleted comment", "Thanks!"} {
+			if !strings.Contains(body, want) {
+				t.Errorf("want body to contain %q", want)
+			}
+		}
+		for _, unwanted := range []string{"Looks good", "Why empty?"} {
+			if strings.Contains(body, unwanted) {
+				t.Errorf("want body not to contain %q", unwanted)
+			}
+		}
+
+		// Once the reply goes too, nothing is left of the thread
+		code, _, _ := post(alice, fmt.Sprintf("/comment/delete/%d", ids[1]), nil)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, _, body = anonymous.get(t, "/snippet/view/"+slug)
+		if !strings.Contains(body, "There are no comments yet.") {
+			t.Errorf("want no comments")
+		}
+	})
+
+	t.Run("Depth", func(t *testing.T) {
+		deepSlug, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Deep thread", Files: oneFile("plaintext", "Content"), Visibility: "public", Expires: inDays(7)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deep, err := app.snippets.GetBySlug(deepSlug)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Each reply answers the one before, as deep as replies can go
+		parent := 0
+		for depth := 1; depth <= maxCommentDepth; depth++ {
+			parent, err = app.comments.Insert(deep.ID, 2, parent, 0, 0, fmt.Sprintf("Depth %d", depth))
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		code, _, body := post(bob, "/snippet/comment/"+deepSlug, map[string]string{"content": "Too deep", "parent": strconv.Itoa(parent)})
+		if code != http.StatusUnprocessableEntity {
+			t.Errorf("want %d; got %d", http.StatusUnprocessableEntity, code)
+		}
+		if want := fmt.Sprintf("Replies can&#39;t be nested more than %d deep", maxCommentDepth); !strings.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+
+		// The deepest comment can't be replied to, the one above it can
+		_, _, body = bob.get(t, "/snippet/view/"+deepSlug)
+		if got := strings.Count(body, `name="parent"`); got != maxCommentDepth-1 {
+			t.Errorf("want %d reply forms; got %d", maxCommentDepth-1, got)
+		}
+
+		// Deleting the snippet takes the whole thread with it
+		code, _, _ = post(alice, "/snippet/delete/"+deepSlug, nil)
+		if code != http.StatusSeeOther {
+			t.Fatalf("want %d; got %d", http.StatusSeeOther, code)
+		}
+
+		_, err = app.comments.Get(parent)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want models.ErrNoRecord; got %v", err)
+		}
+	})
+}
@@ -2,16 +2,29 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"runtime/debug"
+	"strconv"
 	"time"
 
 	"github.com/go-playground/form/v4"
+	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/nosurf"
+	"github.com/koller-m/snippetbox/internal/highlight"
+	"github.com/koller-m/snippetbox/internal/models"
 )
 
+// Returned by decodeJSONBody() if the request isn't application/json
+var errUnsupportedMediaType = errors.New("Content-Type header must be application/json")
+
+// Largest request body the JSON API will accept (1MB)
+const maxJSONBodyBytes = 1_048_576
+
 // Writes error message and stack trace to errorLog
 // Sends generic 500 Internal Server Error
 func (app *application) serverError(w http.ResponseWriter, err error) {
@@ -61,10 +74,12 @@ func (app *application) render(w http.ResponseWriter, status int, page string, d
 
 func (app *application) newTemplateData(r *http.Request) *templateData {
 	return &templateData{
-		CurrentYear:     time.Now().Year(),
-		Flash:           app.sessionManager.PopString(r.Context(), "flash"),
-		IsAuthenticated: app.isAuthenticated(r),
-		CSRFToken:       nosurf.Token(r),
+		CurrentYear:         time.Now().Year(),
+		Flash:               app.sessionManager.PopString(r.Context(), "flash"),
+		IsAuthenticated:     app.isAuthenticated(r),
+		AuthenticatedUserID: app.authenticatedUserID(r),
+		CSRFToken:           nosurf.Token(r),
+		Languages:           highlight.Languages,
 	}
 }
 
@@ -90,7 +105,134 @@ func (app *application) decodePostForm(r *http.Request, dst any) error {
 }
 
 // Return true if the request is from an authenticated user
+// Either by session or, on API routes, by bearer token
 // Otherwise, return false
 func (app *application) isAuthenticated(r *http.Request) bool {
+	if tokenUserID(r) != 0 {
+		return true
+	}
 	return app.sessionManager.Exists(r.Context(), "authenticatedUserID")
 }
+
+// Return true if the request may see the content of a password-protected
+// snippet, either because its owner made it or because the passphrase was
+// entered earlier in this session
+// Snippets without a password are always unlocked
+func (app *application) isUnlocked(r *http.Request, snippet *models.Snippet) bool {
+	if !snippet.HasPassword() || snippet.UserID == app.authenticatedUserID(r) {
+		return true
+	}
+	return app.sessionManager.GetBool(r.Context(), unlockedSnippetKey(snippet))
+}
+
+// Return the session key remembering that a snippet was unlocked
+func unlockedSnippetKey(snippet *models.Snippet) string {
+	return fmt.Sprintf("unlockedSnippet:%d", snippet.ID)
+}
+
+// Return the ID of the logged in user, or 0 if there isn't one
+// A user authenticated by bearer token takes precedence over the session
+func (app *application) authenticatedUserID(r *http.Request) int {
+	if id := tokenUserID(r); id != 0 {
+		return id
+	}
+	return app.sessionManager.GetInt(r.Context(), "authenticatedUserID")
+}
+
+// Read the id param from the URL and convert it to an int
+// Returns an error if it can't be converted or is less than 1
+func (app *application) readIDParam(r *http.Request) (int, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+
+	id, err := strconv.Atoi(params.ByName("id"))
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid id parameter")
+	}
+
+	return id, nil
+}
+
+// Encode data as JSON and write it to the response with the given status
+func (app *application) writeJSON(w http.ResponseWriter, status int, data any) {
+	// Encode to a buffer first so an encoding error can still become a 500
+	buf := new(bytes.Buffer)
+
+	err := json.NewEncoder(buf).Encode(data)
+	if err != nil {
+		app.apiServerError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
+
+// Decode a JSON request body into dst
+// The body must be a single JSON object with no unknown fields
+func (app *application) decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errUnsupportedMediaType
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxJSONBodyBytes)
+
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+
+	err = dec.Decode(dst)
+	if err != nil {
+		var invalidUnmarshalError *json.InvalidUnmarshalError
+
+		// As with decodePostForm(), an invalid destination is a bug, so panic
+		if errors.As(err, &invalidUnmarshalError) {
+			panic(err)
+		}
+
+		return fmt.Errorf("body contains badly-formed JSON: %w", err)
+	}
+
+	// Make sure there is nothing after the first JSON value
+	err = dec.Decode(&struct{}{})
+	if !errors.Is(err, io.EOF) {
+		return errors.New("body must only contain a single JSON value")
+	}
+
+	return nil
+}
+
+// Define apiErrorResponse as the body for every API error
+// FieldErrors and NonFieldErrors come from validator.Validator
+type apiErrorResponse struct {
+	Error          string            `json:"error"`
+	FieldErrors    map[string]string `json:"field_errors,omitempty"`
+	NonFieldErrors []string          `json:"non_field_errors,omitempty"`
+}
+
+// Sends a JSON error body with the given status and message
+func (app *application) apiError(w http.ResponseWriter, status int, message string) {
+	app.writeJSON(w, status, apiErrorResponse{Error: message})
+}
+
+// JSON equivalent of serverError()
+func (app *application) apiServerError(w http.ResponseWriter, err error) {
+	trace := fmt.Sprintf("%s\n%s", err.Error(), debug.Stack())
+	app.errorLog.Output(2, trace)
+
+	// Don't use writeJSON() here, if encoding failed it would loop forever
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	fmt.Fprintf(w, "{\"error\":%q}\n", http.StatusText(http.StatusInternalServerError))
+}
+
+// Sends 401 Unauthorized for a missing or unknown bearer token
+func (app *application) invalidTokenResponse(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	app.apiError(w, http.StatusUnauthorized, "invalid or missing authentication token")
+}
+
+// JSON equivalent of notFound()
+func (app *application) apiNotFound(w http.ResponseWriter) {
+	app.apiError(w, http.StatusNotFound, "the requested resource could not be found")
+}
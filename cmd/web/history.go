@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/koller-m/snippetbox/internal/diff"
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Number of unchanged lines shown either side of a change in a diff
+const diffContext = 3
+
+// Define revisionDiff type for the changes between two revisions
+type revisionDiff struct {
+	From  *models.SnippetRevision
+	To    *models.SnippetRevision
+	Files []fileDiff
+}
+
+// Define fileDiff type for the changes to one of the files
+// From is nil for a file that was added, and To for one that was removed
+type fileDiff struct {
+	Name  string
+	From  *models.SnippetFile
+	To    *models.SnippetFile
+	Hunks []diff.Hunk
+}
+
+// Pair up the files of two revisions and diff each pair
+// Files are matched by filename first, then the rest by position, and any
+// left over were added or removed
+func diffFiles(from, to []*models.SnippetFile) []fileDiff {
+	// The file from the old revision paired with each new file
+	pairs := make([]*models.SnippetFile, len(to))
+	used := make([]bool, len(from))
+
+	for j, t := range to {
+		if t.Filename == "" {
+			continue
+		}
+		for i, f := range from {
+			if !used[i] && f.Filename == t.Filename {
+				pairs[j] = f
+				used[i] = true
+				break
+			}
+		}
+	}
+
+	for j := range to {
+		if pairs[j] == nil && j < len(from) && !used[j] {
+			pairs[j] = from[j]
+			used[j] = true
+		}
+	}
+
+	diffs := []fileDiff{}
+
+	for j, t := range to {
+		old := ""
+		if pairs[j] != nil {
+			old = pairs[j].Content
+		}
+		diffs = append(diffs, fileDiff{
+			Name:  fileName(t, j),
+			From:  pairs[j],
+			To:    t,
+			Hunks: diff.Hunks(old, t.Content, diffContext),
+		})
+	}
+
+	for i, f := range from {
+		if !used[i] {
+			diffs = append(diffs, fileDiff{
+				Name:  fileName(f, i),
+				From:  f,
+				Hunks: diff.Hunks(f.Content, "", diffContext),
+			})
+		}
+	}
+
+	return diffs
+}
+
+// Return the link to the changes made in a revision
+func revisionChangesURL(slug string, revision int) string {
+	return fmt.Sprintf("/snippet/view/%s/diff?from=%d&to=%d", slug, revision-1, revision)
+}
+
+// Return the CSS class for a line of a diff
+func diffClass(op diff.Op) string {
+	switch op {
+	case diff.Delete:
+		return "deleted"
+	case diff.Insert:
+		return "inserted"
+	default:
+		return "unchanged"
+	}
+}
+
+// GET /snippet/view/:slug/history
+// Lists every revision of the snippet, newest first
+func (app *application) snippetHistory(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	revisions, err := app.snippets.Revisions(snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Revisions = revisions
+
+	app.render(w, http.StatusOK, "history.tmpl.html", data)
+}
+
+// GET /snippet/view/:slug/diff?from=1&to=2
+// Shows the changes to each file between two revisions as unified diffs
+func (app *application) snippetDiff(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.Atoi(r.URL.Query().Get("from"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	to, err := strconv.Atoi(r.URL.Query().Get("to"))
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	d := &revisionDiff{}
+
+	d.From, err = app.snippets.Revision(snippet.ID, from)
+	if err == nil {
+		d.To, err = app.snippets.Revision(snippet.ID, to)
+	}
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	d.Files = diffFiles(d.From.Files, d.To.Files)
+
+	data := app.newTemplateData(r)
+	data.Snippet = snippet
+	data.Diff = d
+
+	app.render(w, http.StatusOK, "diff.tmpl.html", data)
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"database/sql"
 	"flag"
+	"fmt"
 	"html/template"
 	"log"
 	"net/http"
@@ -11,20 +12,28 @@ import (
 	"time"
 
 	// Import the models package
+	"github.com/koller-m/snippetbox/internal/migrations"
 	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/internal/models/sqlite"
 
 	"github.com/alexedwards/scs/mysqlstore"
+	"github.com/alexedwards/scs/sqlite3store"
 	"github.com/alexedwards/scs/v2"
 	"github.com/go-playground/form/v4"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 // Create application struct to hold application-wide dependencies
+// The models are interfaces so the storage backend can be swapped
 type application struct {
 	errorLog       *log.Logger
 	infoLog        *log.Logger
-	snippets       *models.SnippetModel
-	users          *models.UserModel
+	snippets       models.SnippetModelInterface
+	users          models.UserModelInterface
+	tokens         models.TokenModelInterface
+	collections    models.CollectionModelInterface
+	stars          models.StarModelInterface
+	comments       models.CommentModelInterface
 	templateCache  map[string]*template.Template
 	formDecoder    *form.Decoder
 	sessionManager *scs.SessionManager
@@ -34,9 +43,25 @@ func main() {
 	// Define a command-line flag "addr"
 	addr := flag.String("addr", ":4000", "HTTP network address")
 
-	// Define command-line flag for MySQL DSN string
+	// Define command-line flag for the storage backend
+	dbDriver := flag.String("db-driver", "mysql", "Database driver (mysql or sqlite)")
+
+	// Define command-line flag for the DSN string
+	// If it's not set, a default for the driver is used
 	// REMOVE pass
-	dsn := flag.String("dsn", "web:pass@/snippetbox?parseTime=true", "MySQL data source name")
+	dsn := flag.String("dsn", "", "Data source name (default depends on -db-driver)")
+
+	// Define command-line flags for the expired snippet reaper
+	// An interval of 0 turns the reaper off
+	reapInterval := flag.Duration("reap-interval", time.Hour, "How often to delete expired snippets (0 to disable)")
+	reapBatch := flag.Int("reap-batch", 1000, "Maximum number of expired snippets deleted per query")
+
+	// Define command-line flag for how long in-flight requests get to
+	// finish when the server is asked to stop
+	shutdownTimeout := flag.Duration("shutdown-timeout", 20*time.Second, "Time allowed for graceful shutdown")
+
+	// Define command-line flag to run migrations instead of the server
+	migrate := flag.String("migrate", "", "Run database migrations (up, down or status) and exit")
 
 	// Parse the command-line flag with flag.Parse()
 	// This reads in the command-line flag and assigns it to addr
@@ -52,7 +77,11 @@ func main() {
 	// Create a logger for writing error messages
 	errorLog := log.New(os.Stderr, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
 
-	db, err := openDB(*dsn)
+	if *dsn == "" {
+		*dsn = defaultDSN(*dbDriver)
+	}
+
+	db, err := openDB(*dbDriver, *dsn)
 	if err != nil {
 		errorLog.Fatal(err)
 	}
@@ -60,6 +89,17 @@ func main() {
 	// Close the connection pool before main() exits
 	defer db.Close()
 
+	// If -migrate is set, run the migrations and exit
+	if *migrate != "" {
+		migrator := &migrations.Migrator{DB: db, Dialect: *dbDriver}
+
+		err = runMigrations(migrator, *migrate, infoLog)
+		if err != nil {
+			errorLog.Fatal(err)
+		}
+		return
+	}
+
 	// Init new template cache
 	templateCache, err := newTemplateCache()
 	if err != nil {
@@ -70,10 +110,10 @@ func main() {
 	formDecoder := form.NewDecoder()
 
 	// Use scs.New() to init new session manager
-	// Config MySQL as the session store and set a lifetime of 12 hours
+	// Sessions are kept in the same database as everything else
+	// Set a lifetime of 12 hours
 	// Sessions automatically expire 12 hours after being created
 	sessionManager := scs.New()
-	sessionManager.Store = mysqlstore.New(db)
 	sessionManager.Lifetime = 12 * time.Hour
 	sessionManager.Cookie.Secure = true
 
@@ -81,13 +121,45 @@ func main() {
 	app := &application{
 		errorLog:       errorLog,
 		infoLog:        infoLog,
-		snippets:       &models.SnippetModel{DB: db},
-		users:          &models.UserModel{DB: db},
 		templateCache:  templateCache,
 		formDecoder:    formDecoder,
 		sessionManager: sessionManager,
 	}
 
+	// Plug in the models and session store for the chosen driver
+	// Both session stores run a cleanup goroutine that must be stopped
+	// before the database is closed
+	var stopSessionCleanup func()
+
+	switch *dbDriver {
+	case "mysql":
+		app.snippets = &models.SnippetModel{DB: db}
+		app.users = &models.UserModel{DB: db}
+		app.tokens = &models.TokenModel{DB: db}
+		app.collections = &models.CollectionModel{DB: db}
+		app.stars = &models.StarModel{DB: db}
+		app.comments = &models.CommentModel{DB: db}
+		store := mysqlstore.New(db)
+		sessionManager.Store = store
+		stopSessionCleanup = store.StopCleanup
+	case "sqlite":
+		app.snippets = &sqlite.SnippetModel{DB: db}
+		app.users = &sqlite.UserModel{DB: db}
+		app.tokens = &sqlite.TokenModel{DB: db}
+		app.collections = &sqlite.CollectionModel{DB: db}
+		app.stars = &sqlite.StarModel{DB: db}
+		app.comments = &sqlite.CommentModel{DB: db}
+		store := sqlite3store.New(db)
+		sessionManager.Store = store
+		stopSessionCleanup = store.StopCleanup
+	}
+
+	// Start purging expired snippets in the background
+	stopReaper := func() {}
+	if *reapInterval > 0 {
+		stopReaper = app.startReaper(*reapInterval, *reapBatch)
+	}
+
 	// Init tls.Config struct to hold non-default settings
 	tlsConfig := &tls.Config{
 		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
@@ -106,18 +178,51 @@ func main() {
 	}
 
 	infoLog.Printf("Starting server on %s", *addr)
-	// Use ListenAndServeTLS() to start HTTPS server
-	err = srv.ListenAndServeTLS("./tls/cert.pem", "./tls/key.pem")
-	errorLog.Fatal(err)
+	// Serve HTTPS until SIGINT or SIGTERM, then shut down gracefully
+	err = app.serve(srv, "./tls/cert.pem", "./tls/key.pem", *shutdownTimeout)
+
+	// Wait for background work to finish before closing the database
+	stopReaper()
+	stopSessionCleanup()
+	db.Close()
+
+	// Exit with status 1 if the server failed or didn't stop in time
+	if err != nil {
+		errorLog.Fatal(err)
+	}
+
+	infoLog.Print("Stopped server")
+}
+
+// defaultDSN() returns the DSN used when the -dsn flag isn't set
+func defaultDSN(driver string) string {
+	if driver == "sqlite" {
+		return "file:snippetbox.db?_foreign_keys=on&_busy_timeout=5000"
+	}
+	return "web:pass@/snippetbox?parseTime=true"
 }
 
 // openDB() wraps sql.Open() and returns a sql.DB connection pool
-func openDB(dsn string) (*sql.DB, error) {
-	db, err := sql.Open("mysql", dsn)
+// The schema is created separately with -migrate=up
+func openDB(driver, dsn string) (*sql.DB, error) {
+	var driverName string
+
+	switch driver {
+	case "mysql":
+		driverName = "mysql"
+	case "sqlite":
+		driverName = "sqlite3"
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return nil, err
 	}
+
 	if err = db.Ping(); err != nil {
+		db.Close()
 		return nil, err
 	}
 	return db, nil
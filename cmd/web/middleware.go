@@ -1,8 +1,13 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+
+	"github.com/koller-m/snippetbox/internal/models"
 
 	"github.com/justinas/nosurf"
 )
@@ -63,8 +68,76 @@ func (app *application) requireAuthentication(next http.Handler) http.Handler {
 	})
 }
 
+// Check for an Authorization: Bearer header
+// If the token is valid, add the user ID to the request context
+// A request without the header carries on to the session as normal
+func (app *application) authenticateToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The response depends on the Authorization header
+		w.Header().Add("Vary", "Authorization")
+
+		header := r.Header.Get("Authorization")
+		if header == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A bad token is an error rather than falling back to the session
+		scheme, token, ok := strings.Cut(header, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+			app.invalidTokenResponse(w)
+			return
+		}
+
+		userID, err := app.tokens.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, models.ErrInvalidCredentials) {
+				app.invalidTokenResponse(w)
+			} else {
+				app.apiServerError(w, err)
+			}
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), tokenUserIDContextKey, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// JSON API equivalent of requireAuthentication
+// Accepts a session or a bearer token from authenticateToken
+// Responds with 401 Unauthorized instead of redirecting
+func (app *application) requireAPIAuthentication(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !app.isAuthenticated(r) {
+			app.apiError(w, http.StatusUnauthorized, "you must be authenticated to access this resource")
+			return
+		}
+
+		w.Header().Add("Cache-Control", "no-store")
+
+		next.ServeHTTP(w, r)
+	})
+}
+
 // Create middleware function that uses a custom CSRF cookie
 func noSurf(next http.Handler) http.Handler {
+	return newCSRFHandler(next)
+}
+
+// JSON API equivalent of noSurf
+// Responds to a failed CSRF check with a JSON error instead of plain text
+func (app *application) apiNoSurf(next http.Handler) http.Handler {
+	csrfHandler := newCSRFHandler(next)
+	csrfHandler.SetFailureHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.apiError(w, http.StatusBadRequest, "invalid or missing CSRF token")
+	}))
+
+	return csrfHandler
+}
+
+// Create the nosurf handler shared by noSurf and apiNoSurf
+func newCSRFHandler(next http.Handler) *nosurf.CSRFHandler {
 	csrfHandler := nosurf.New(next)
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
@@ -72,5 +145,11 @@ func noSurf(next http.Handler) http.Handler {
 		Secure:   true,
 	})
 
+	// Requests authenticated by bearer token don't use cookies, so they
+	// can't be forged cross-site and don't need a CSRF token
+	csrfHandler.ExemptFunc(func(r *http.Request) bool {
+		return tokenUserID(r) != 0
+	})
+
 	return csrfHandler
 }
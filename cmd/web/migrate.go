@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/koller-m/snippetbox/internal/migrations"
+)
+
+// Run a -migrate command: "up", "down" or "status"
+// Progress is written to infoLog
+func runMigrations(m *migrations.Migrator, command string, infoLog *log.Logger) error {
+	switch command {
+	case "up":
+		applied, err := m.Up()
+		for _, mg := range applied {
+			infoLog.Printf("Applied migration %04d_%s", mg.Version, mg.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			infoLog.Print("Database is already up to date")
+		}
+
+	case "down":
+		mg, err := m.Down()
+		if err != nil {
+			if errors.Is(err, migrations.ErrNoChange) {
+				infoLog.Print("No migrations to roll back")
+				return nil
+			}
+			return err
+		}
+		infoLog.Printf("Rolled back migration %04d_%s", mg.Version, mg.Name)
+
+	case "status":
+		statuses, err := m.Status()
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			if s.Applied {
+				infoLog.Printf("%04d_%s\tapplied %s", s.Version, s.Name, humanDate(s.AppliedAt))
+			} else {
+				infoLog.Printf("%04d_%s\tpending", s.Version, s.Name)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown -migrate command %q, want up, down or status", command)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// Number of snippets shown on each page of a listing
+const listPageSize = 10
+
+// Define pagination type to hold the details the templates need to render
+// page links for a listing
+type pagination struct {
+	CurrentPage  int
+	PageSize     int
+	TotalRecords int
+	LastPage     int
+	path         string
+	query        url.Values
+}
+
+// Highest page that can be asked for, so the offset of its first snippet
+// can't overflow
+const maxPage = math.MaxInt / listPageSize
+
+// Read the page query string param from r, defaulting to 1
+// Returns an error if it isn't a positive integer up to maxPage
+func (app *application) readPage(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("page")
+	if s == "" {
+		return 1, nil
+	}
+
+	page, err := strconv.Atoi(s)
+	if err != nil || page < 1 {
+		return 0, errors.New("page must be a positive integer")
+	}
+
+	if page > maxPage {
+		return 0, errors.New("page is too large")
+	}
+
+	return page, nil
+}
+
+// Create a pagination for r, keeping its other query string params so page
+// links stay on the same search or filter
+func newPagination(r *http.Request, page, pageSize, totalRecords int) *pagination {
+	lastPage := (totalRecords + pageSize - 1) / pageSize
+	if lastPage < 1 {
+		lastPage = 1
+	}
+
+	return &pagination{
+		CurrentPage:  page,
+		PageSize:     pageSize,
+		TotalRecords: totalRecords,
+		LastPage:     lastPage,
+		path:         r.URL.Path,
+		query:        r.URL.Query(),
+	}
+}
+
+func (p *pagination) HasPrevious() bool {
+	return p.CurrentPage > 1
+}
+
+func (p *pagination) HasNext() bool {
+	return p.CurrentPage < p.LastPage
+}
+
+func (p *pagination) PreviousPage() int {
+	return p.CurrentPage - 1
+}
+
+func (p *pagination) NextPage() int {
+	return p.CurrentPage + 1
+}
+
+// URL returns the link to the given page of the listing
+func (p *pagination) URL(page int) string {
+	query := url.Values{}
+	for k, v := range p.query {
+		query[k] = v
+	}
+
+	if page > 1 {
+		query.Set("page", strconv.Itoa(page))
+	} else {
+		query.Del("page")
+	}
+
+	u := url.URL{Path: p.path, RawQuery: query.Encode()}
+	return u.String()
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/koller-m/snippetbox/internal/highlight"
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// GET /snippet/raw/:slug/:file
+// The content of one of the snippet's files exactly as stored, so whitespace
+// and line endings survive
+// Files are numbered from 1, and /snippet/raw/:slug serves the first one
+func (app *application) snippetRaw(w http.ResponseWriter, r *http.Request) {
+	snippet, index, ok := app.snippetFileFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	app.serveSnippetContent(w, r, snippet, index)
+}
+
+// GET /snippet/download/:slug/:file
+// Same as the raw endpoint, but saved as a file named after the file, or
+// the snippet if it has no filename
+func (app *application) snippetDownload(w http.ResponseWriter, r *http.Request) {
+	snippet, index, ok := app.snippetFileFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	disposition := mime.FormatMediaType("attachment", map[string]string{
+		"filename": snippetFilename(snippet, index),
+	})
+	w.Header().Set("Content-Disposition", disposition)
+
+	app.serveSnippetContent(w, r, snippet, index)
+}
+
+// Fetch the snippet with the slug from the URL, and the index in its files
+// of the file param
+// If there is no such snippet or file, an error response is sent and ok is
+// false
+func (app *application) snippetFileFromURL(w http.ResponseWriter, r *http.Request) (snippet *models.Snippet, index int, ok bool) {
+	snippet, ok = app.snippetFromURL(w, r)
+	if !ok {
+		return nil, 0, false
+	}
+
+	param := httprouter.ParamsFromContext(r.Context()).ByName("file")
+	if param == "" {
+		return snippet, 0, true
+	}
+
+	position, err := strconv.Atoi(param)
+	if err != nil || position < 1 || position > len(snippet.Files) {
+		app.notFound(w)
+		return nil, 0, false
+	}
+
+	return snippet, position - 1, true
+}
+
+// Write the content of one of the snippet's files as plain text
+// http.ServeContent answers conditional requests using the ETag and
+// Last-Modified headers, so unchanged snippets get a 304 Not Modified
+// Reading a burn after reading snippet this way burns it too, and locked
+// snippets redirect to the view page to ask for the passphrase
+func (app *application) serveSnippetContent(w http.ResponseWriter, r *http.Request, snippet *models.Snippet, index int) {
+	if !app.isUnlocked(r, snippet) {
+		http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+		return
+	}
+
+	// A burn after reading snippet may only go once someone has its content,
+	// so a HEAD request, which gets no body, can't be allowed to burn it
+	if r.Method == http.MethodHead && app.burnsOnRead(r, snippet) {
+		w.Header().Set("Allow", http.MethodGet)
+		app.clientError(w, http.StatusMethodNotAllowed)
+		return
+	}
+
+	burned, err := app.burnSnippet(r, snippet)
+	if err != nil {
+		if errors.Is(err, models.ErrNoRecord) {
+			app.notFound(w)
+		} else {
+			app.serverError(w, err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	// Clients have to revalidate every time, so edited, deleted and expired
+	// snippets are never served from a stale cache
+	// Only public snippets without a password may be stored by shared
+	// caches, and burned ones can't be stored at all
+	// A burned snippet always gets the whole body, as it's the only chance
+	// to read it, so it has no validators and conditional and range headers
+	// are ignored
+	modtime := snippet.Updated
+	if burned {
+		w.Header().Set("Cache-Control", "no-store")
+
+		r = r.Clone(r.Context())
+		for _, h := range []string{"If-Match", "If-None-Match", "If-Modified-Since", "If-Unmodified-Since", "If-Range", "Range"} {
+			r.Header.Del(h)
+		}
+		modtime = time.Time{}
+	} else {
+		w.Header().Set("ETag", snippetETag(snippet, index))
+
+		if snippet.Visibility == models.VisibilityPublic && !snippet.HasPassword() {
+			w.Header().Set("Cache-Control", "no-cache")
+		} else {
+			w.Header().Set("Cache-Control", "private, no-cache")
+		}
+	}
+
+	http.ServeContent(w, r, "", modtime, strings.NewReader(snippet.Files[index].Content))
+}
+
+// Return a strong ETag for everything the raw and download responses for
+// a file contain
+func snippetETag(snippet *models.Snippet, index int) string {
+	file := snippet.Files[index]
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\x00%d\x00%s\x00%s\x00%s\x00%s", snippet.ID, index, snippet.Title, file.Filename, file.Language, file.Content)
+
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// Return the download filename of one of the snippet's files
+// Files without a filename are named after the snippet, like
+// "an-old-silent-pond.txt", or "an-old-silent-pond-2.txt" for the second
+// file. The title is reduced to lowercase ASCII letters and digits joined
+// by hyphens, falling back to the slug if nothing is left
+func snippetFilename(snippet *models.Snippet, index int) string {
+	file := snippet.Files[index]
+	if file.Filename != "" {
+		return file.Filename
+	}
+
+	var b strings.Builder
+	hyphen := false
+
+	for _, c := range strings.ToLower(snippet.Title) {
+		if (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(c)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	name := b.String()
+	if name == "" {
+		name = "snippet-" + snippet.Slug
+	}
+
+	if index > 0 {
+		name += fmt.Sprintf("-%d", index+1)
+	}
+
+	return name + "." + highlight.Extension(file.Language)
+}
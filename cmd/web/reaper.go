@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Start a background goroutine that purges expired snippets once straight
+// away and then every interval
+// Call the returned stop function to end it, it waits for any batch in
+// progress to finish
+func (app *application) startReaper(interval time.Duration, batchSize int) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		// Don't leave snippets that expired while the server was down
+		// around for a whole interval
+		app.reapExpiredSnippets(ctx, batchSize)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				app.reapExpiredSnippets(ctx, batchSize)
+			}
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+// Delete expired snippets in batches of batchSize until none are left or
+// ctx is cancelled
+// Returns the total number deleted
+func (app *application) reapExpiredSnippets(ctx context.Context, batchSize int) int {
+	total := 0
+
+	for ctx.Err() == nil {
+		n, err := app.snippets.DeleteExpired(batchSize)
+		if err != nil {
+			app.errorLog.Printf("reaper: %s", err)
+			break
+		}
+
+		total += n
+
+		// A short batch means there's nothing left to delete
+		if n < batchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		app.infoLog.Printf("Deleted %d expired snippets", total)
+	}
+
+	return total
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+func TestReapExpiredSnippets(t *testing.T) {
+	app := newTestApplication(t)
+
+	// A negative expiry puts the snippet in the past
+	for i := 0; i < 5; i++ {
+		_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Expired", Files: oneFile("plaintext", "Gone"), Visibility: "public", Expires: inDays(-1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	live, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Live", Files: oneFile("plaintext", "Still here"), Visibility: "public", Expires: inDays(7)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch size smaller than the number of expired snippets checks
+	// that it keeps going until they're all gone
+	deleted := app.reapExpiredSnippets(context.Background(), 2)
+	if deleted != 5 {
+		t.Errorf("want 5 deleted; got %d", deleted)
+	}
+
+	_, err = app.snippets.GetBySlug(live)
+	if errors.Is(err, models.ErrNoRecord) {
+		t.Error("want live snippet to be kept")
+	}
+
+	if deleted := app.reapExpiredSnippets(context.Background(), 2); deleted != 0 {
+		t.Errorf("want 0 deleted on second run; got %d", deleted)
+	}
+}
+
+// Wraps a snippet model to signal each call to DeleteExpired()
+type deleteExpiredSignal struct {
+	models.SnippetModelInterface
+	called chan struct{}
+}
+
+func (m *deleteExpiredSignal) DeleteExpired(limit int) (int, error) {
+	n, err := m.SnippetModelInterface.DeleteExpired(limit)
+
+	select {
+	case m.called <- struct{}{}:
+	default:
+	}
+
+	return n, err
+}
+
+func TestStartReaper(t *testing.T) {
+	app := newTestApplication(t)
+
+	snippets := &deleteExpiredSignal{SnippetModelInterface: app.snippets, called: make(chan struct{}, 1)}
+	app.snippets = snippets
+
+	_, err := app.snippets.Insert(models.NewSnippet{UserID: 1, Title: "Expired", Files: oneFile("plaintext", "Gone"), Visibility: "public", Expires: inDays(-1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The interval is far longer than the test, so only the reap at start
+	// can delete the snippet
+	stop := app.startReaper(time.Hour, 10)
+
+	select {
+	case <-snippets.called:
+	case <-time.After(5 * time.Second):
+		t.Fatal("want a reap when the reaper starts")
+	}
+
+	stop()
+
+	deleted, err := app.snippets.DeleteExpired(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deleted != 0 {
+		t.Errorf("want the expired snippet already deleted; got %d left", deleted)
+	}
+}
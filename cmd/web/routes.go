@@ -3,6 +3,8 @@ package main
 import (
 	"net/http"
 
+	"github.com/koller-m/snippetbox/ui"
+
 	"github.com/julienschmidt/httprouter"
 	"github.com/justinas/alice"
 )
@@ -17,9 +19,10 @@ func (app *application) routes() http.Handler {
 		app.notFound(w)
 	})
 
-	// Update route pattern for static files
-	fileServer := http.FileServer(http.Dir("./ui/static/"))
-	router.Handler(http.MethodGet, "/static/*filepath", http.StripPrefix("/static", fileServer))
+	// Serve static files from the embedded filesystem
+	// The files are under "static/" in ui.Files, so no prefix is stripped
+	fileServer := http.FileServer(http.FS(ui.Files))
+	router.Handler(http.MethodGet, "/static/*filepath", fileServer)
 
 	// Use nosurf middleware on all dynamic routes
 	// Unprotected routes
@@ -28,7 +31,21 @@ func (app *application) routes() http.Handler {
 
 	// Update routes to use dynamic middleware chain
 	router.Handler(http.MethodGet, "/", dynamic.ThenFunc(app.home))
-	router.Handler(http.MethodGet, "/snippet/view/:id", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/view/:slug", dynamic.ThenFunc(app.snippetView))
+	router.Handler(http.MethodGet, "/snippet/view/:slug/history", dynamic.ThenFunc(app.snippetHistory))
+	router.Handler(http.MethodGet, "/snippet/view/:slug/diff", dynamic.ThenFunc(app.snippetDiff))
+	router.Handler(http.MethodPost, "/snippet/unlock/:slug", dynamic.ThenFunc(app.snippetUnlockPost))
+	router.Handler(http.MethodGet, "/snippet/raw/:slug", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/raw/:slug/:file", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodGet, "/snippet/download/:slug", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/download/:slug/:file", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodHead, "/snippet/raw/:slug", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodHead, "/snippet/raw/:slug/:file", dynamic.ThenFunc(app.snippetRaw))
+	router.Handler(http.MethodHead, "/snippet/download/:slug", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodHead, "/snippet/download/:slug/:file", dynamic.ThenFunc(app.snippetDownload))
+	router.Handler(http.MethodGet, "/snippet/search", dynamic.ThenFunc(app.snippetSearch))
+	router.Handler(http.MethodGet, "/tag/:name", dynamic.ThenFunc(app.snippetTag))
+	router.Handler(http.MethodGet, "/collection/:slug", dynamic.ThenFunc(app.collectionView))
 	router.Handler(http.MethodGet, "/user/signup", dynamic.ThenFunc(app.userSignup))
 	router.Handler(http.MethodPost, "/user/signup", dynamic.ThenFunc(app.userSignupPost))
 	router.Handler(http.MethodGet, "/user/login", dynamic.ThenFunc(app.userLogin))
@@ -40,7 +57,38 @@ func (app *application) routes() http.Handler {
 
 	router.Handler(http.MethodGet, "/snippet/create", protected.ThenFunc(app.snippetCreate))
 	router.Handler(http.MethodPost, "/snippet/create", protected.ThenFunc(app.snippetCreatePost))
+	router.Handler(http.MethodGet, "/snippet/fork/:slug", protected.ThenFunc(app.snippetFork))
+	router.Handler(http.MethodGet, "/snippet/edit/:slug", protected.ThenFunc(app.snippetEdit))
+	router.Handler(http.MethodPost, "/snippet/edit/:slug", protected.ThenFunc(app.snippetEditPost))
+	router.Handler(http.MethodPost, "/snippet/delete/:slug", protected.ThenFunc(app.snippetDeletePost))
+	router.Handler(http.MethodPost, "/snippet/collect/:slug", protected.ThenFunc(app.snippetCollectPost))
+	router.Handler(http.MethodPost, "/snippet/star/:slug", protected.ThenFunc(app.snippetStarPost))
+	router.Handler(http.MethodPost, "/snippet/unstar/:slug", protected.ThenFunc(app.snippetUnstarPost))
+	router.Handler(http.MethodPost, "/snippet/comment/:slug", protected.ThenFunc(app.snippetCommentPost))
+	router.Handler(http.MethodPost, "/comment/delete/:id", protected.ThenFunc(app.commentDeletePost))
+	router.Handler(http.MethodGet, "/user/starred", protected.ThenFunc(app.userStarred))
+	router.Handler(http.MethodGet, "/collections", protected.ThenFunc(app.collectionList))
+	router.Handler(http.MethodPost, "/collections", protected.ThenFunc(app.collectionCreatePost))
+	router.Handler(http.MethodGet, "/collection/:slug/edit", protected.ThenFunc(app.collectionEdit))
+	router.Handler(http.MethodPost, "/collection/:slug/edit", protected.ThenFunc(app.collectionEditPost))
+	router.Handler(http.MethodPost, "/collection/:slug/delete", protected.ThenFunc(app.collectionDeletePost))
+	router.Handler(http.MethodPost, "/collection/:slug/remove", protected.ThenFunc(app.collectionRemovePost))
+	router.Handler(http.MethodPost, "/collection/:slug/move", protected.ThenFunc(app.collectionMovePost))
 	router.Handler(http.MethodPost, "/user/logout", protected.ThenFunc(app.userLogoutPost))
+	router.Handler(http.MethodGet, "/account/tokens", protected.ThenFunc(app.accountTokens))
+	router.Handler(http.MethodPost, "/account/tokens", protected.ThenFunc(app.accountTokensPost))
+	router.Handler(http.MethodPost, "/account/tokens/revoke/:id", protected.ThenFunc(app.accountTokenRevokePost))
+
+	// JSON API routes
+	// Clients can authenticate with a bearer token or the session cookie
+	// Cookie requests still need an X-CSRF-Token header for nosurf
+	api := alice.New(app.sessionManager.LoadAndSave, app.authenticateToken, app.apiNoSurf)
+	apiProtected := api.Append(app.requireAPIAuthentication)
+
+	router.Handler(http.MethodGet, "/api/v1/snippets", api.ThenFunc(app.apiSnippetList))
+	router.Handler(http.MethodGet, "/api/v1/snippets/:slug", api.ThenFunc(app.apiSnippetGet))
+	router.Handler(http.MethodPost, "/api/v1/snippets", apiProtected.ThenFunc(app.apiSnippetCreate))
+	router.Handler(http.MethodDelete, "/api/v1/snippets/:slug", apiProtected.ThenFunc(app.apiSnippetDelete))
 
 	// Create middleware chain which will be used for every request
 	standard := alice.New(app.recoverPanic, app.logRequest, secureHeaders)
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Run srv with ListenAndServeTLS() until the process gets SIGINT or SIGTERM
+// In-flight requests then get up to timeout to finish
+// Returns nil on a clean shutdown
+func (app *application) serve(srv *http.Server, certFile, keyFile string, timeout time.Duration) error {
+	shutdownError := make(chan error)
+
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+
+		s := <-quit
+		app.infoLog.Printf("Shutting down server (%s)", s)
+
+		// Shutdown() stops accepting connections and waits for active
+		// requests, until the context times out
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		shutdownError <- srv.Shutdown(ctx)
+	}()
+
+	// ListenAndServeTLS() returns ErrServerClosed straight away once
+	// Shutdown() is called, so anything else is a real error
+	err := srv.ListenAndServeTLS(certFile, keyFile)
+	if !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	// Wait for Shutdown() to finish
+	return <-shutdownError
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// How the home page can be sorted, with the sort query string param
+const (
+	sortNewest = ""
+	sortStars  = "stars"
+)
+
+// POST /snippet/star/:slug
+// Starring a snippet the user has already starred does nothing
+func (app *application) snippetStarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.readableSnippet(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.stars.Insert(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// POST /snippet/unstar/:slug
+func (app *application) snippetUnstarPost(w http.ResponseWriter, r *http.Request) {
+	snippet, ok := app.snippetFromURL(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.stars.Delete(app.authenticatedUserID(r), snippet.ID)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/snippet/view/%s", snippet.Slug), http.StatusSeeOther)
+}
+
+// GET /user/starred?page=N
+// Lists the snippets the user has starred, most recently starred first
+func (app *application) userStarred(w http.ResponseWriter, r *http.Request) {
+	page, err := app.readPage(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := app.stars.Starred(app.authenticatedUserID(r), listPageSize, (page-1)*listPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Snippets = snippets
+	data.Pagination = newPagination(r, page, listPageSize, total)
+
+	app.render(w, http.StatusOK, "starred.tmpl.html", data)
+}
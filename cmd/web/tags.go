@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/koller-m/snippetbox/internal/validator"
+)
+
+// Maximum number of tags on a snippet
+const maxSnippetTags = 10
+
+// Define tagList type for the tags field of the snippet forms, a list of
+// tags separated by commas or spaces
+type tagList string
+
+// The API can also send the tags as a JSON array
+func (t *tagList) UnmarshalJSON(data []byte) error {
+	var tags []string
+	if err := json.Unmarshal(data, &tags); err == nil {
+		*t = tagList(strings.Join(tags, ","))
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*t = tagList(s)
+	return nil
+}
+
+// Return the tags as a list, to pre-fill a form
+func newTagList(tags []string) tagList {
+	return tagList(strings.Join(tags, ", "))
+}
+
+// Normalized returns the tags in the list, see validator.NormalizeTags()
+func (t tagList) Normalized() []string {
+	return validator.NormalizeTags(string(t))
+}
+
+// Validate the tags of a form
+func validateTags(v *validator.Validator, tags tagList) {
+	normalized := tags.Normalized()
+
+	v.CheckField(len(normalized) <= maxSnippetTags, "tags", fmt.Sprintf("A snippet can't have more than %d tags", maxSnippetTags))
+
+	for _, tag := range normalized {
+		if !validator.ValidTag(tag) {
+			v.AddFieldError("tags", fmt.Sprintf("Tags can only use a-z, 0-9, - + # and . and be up to %d characters long", validator.MaxTagChars))
+			break
+		}
+	}
+}
+
+// Return the link to the listing of a tag
+// Tags can contain # and +, so the tag is escaped
+func tagURL(tag string) string {
+	return "/tag/" + url.PathEscape(tag)
+}
+
+// GET /tag/:name?page=N
+// Lists the public snippets with the tag, newest first
+func (app *application) snippetTag(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(httprouter.ParamsFromContext(r.Context()).ByName("name"))
+	if !validator.ValidTag(tag) {
+		app.notFound(w)
+		return
+	}
+
+	page, err := app.readPage(r)
+	if err != nil {
+		app.clientError(w, http.StatusBadRequest)
+		return
+	}
+
+	snippets, total, err := app.snippets.ByTag(tag, listPageSize, (page-1)*listPageSize)
+	if err != nil {
+		app.serverError(w, err)
+		return
+	}
+
+	data := app.newTemplateData(r)
+	data.Tag = tag
+	data.Snippets = snippets
+	data.Pagination = newPagination(r, page, listPageSize, total)
+
+	app.render(w, http.StatusOK, "tag.tmpl.html", data)
+}
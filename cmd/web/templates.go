@@ -1,22 +1,45 @@
 package main
 
 import (
+	"fmt"
 	"html/template"
+	"io/fs"
 	"path/filepath"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/koller-m/snippetbox/internal/highlight"
 	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/ui"
 )
 
 // Define templateData type to hold dynamic data for HTML templates
 type templateData struct {
-	CurrentYear     int
-	Snippet         *models.Snippet
-	Snippets        []*models.Snippet
-	Form            any
-	Flash           string
-	IsAuthenticated bool
-	CSRFToken       string
+	CurrentYear         int
+	Snippet             *models.Snippet
+	Original            *models.Snippet
+	Burned              bool
+	Snippets            []*models.Snippet
+	Revisions           []*models.SnippetRevision
+	Diff                *revisionDiff
+	Pagination          *pagination
+	Query               string
+	Sort                string
+	Starred             bool
+	Tag                 string
+	Tokens              []*models.Token
+	Collection          *models.Collection
+	Collections         []*models.Collection
+	Comments            []*models.Comment
+	Languages           []highlight.Language
+	NewToken            string
+	Form                any
+	Flash               string
+	IsAuthenticated     bool
+	AuthenticatedUserID int
+	CSRFToken           string
 }
 
 // Format the time
@@ -24,18 +47,190 @@ func humanDate(t time.Time) string {
 	return t.Format("02 Jan 2006 at 15:04")
 }
 
+// Return a case-insensitive regexp matching any of the words in query
+// Returns nil if there are no words
+func searchTermsRX(query string) *regexp.Regexp {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	for i := range terms {
+		terms[i] = regexp.QuoteMeta(terms[i])
+	}
+
+	return regexp.MustCompile("(?i)" + strings.Join(terms, "|"))
+}
+
+// Escape s and wrap every match of rx in a <mark> element
+func markMatches(s string, rx *regexp.Regexp) template.HTML {
+	if rx == nil {
+		return template.HTML(template.HTMLEscapeString(s))
+	}
+
+	var b strings.Builder
+	last := 0
+
+	for _, loc := range rx.FindAllStringIndex(s, -1) {
+		b.WriteString(template.HTMLEscapeString(s[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(template.HTMLEscapeString(s[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(template.HTMLEscapeString(s[last:]))
+
+	return template.HTML(b.String())
+}
+
+// Highlight the words of a search query in s
+func highlightQuery(s, query string) template.HTML {
+	return markMatches(s, searchTermsRX(query))
+}
+
+// Number of bytes of context shown either side of the first match
+const excerptContext = 80
+
+// Return a highlighted fragment of s around the first match of query
+// If nothing matches, the start of s is used
+func excerpt(s, query string) template.HTML {
+	rx := searchTermsRX(query)
+
+	start := 0
+	if rx != nil {
+		if loc := rx.FindStringIndex(s); loc != nil {
+			start = loc[0] - excerptContext
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + 2*excerptContext
+	if end > len(s) {
+		end = len(s)
+	}
+
+	// Move the ends onto rune boundaries
+	for start > 0 && !utf8.RuneStart(s[start]) {
+		start--
+	}
+	for end < len(s) && !utf8.RuneStart(s[end]) {
+		end++
+	}
+
+	fragment := markMatches(s[start:end], rx)
+
+	if start > 0 {
+		fragment = "&hellip;" + fragment
+	}
+	if end < len(s) {
+		fragment += "&hellip;"
+	}
+
+	return fragment
+}
+
+// Render snippet content with syntax highlighting
+// Falls back to plain escaped text if the content can't be highlighted
+func highlightCode(content, language string) template.HTML {
+	code, err := highlight.Code(content, language)
+	if err != nil {
+		return template.HTML("<pre><code>" + template.HTMLEscapeString(content) + "</code></pre>")
+	}
+	return code
+}
+
+// Render a file of a snippet like highlightCode, with ids on the line
+// numbers so comments can link to them
+// index is the position of the file in the snippet, from 0
+func highlightFile(file *models.SnippetFile, index int) template.HTML {
+	code, err := highlight.CodeWithLineIDs(file.Content, file.Language, lineIDPrefix(index+1))
+	if err != nil {
+		return highlightCode(file.Content, file.Language)
+	}
+	return code
+}
+
+// Define commentNode type for rendering a comment in the recursive comment
+// template, which needs the page data for its reply and delete forms
+// Depth is 1 for a top level comment
+type commentNode struct {
+	*models.Comment
+	Page  *templateData
+	Depth int
+}
+
+// CanDelete reports whether the user viewing the page can delete the comment
+// That's its author and the owner of the snippet
+func (n commentNode) CanDelete() bool {
+	userID := n.Page.AuthenticatedUserID
+	return userID != 0 && !n.Deleted && (n.UserID == userID || n.Page.Snippet.UserID == userID)
+}
+
+// CanReply reports whether the user viewing the page can reply to the comment
+// Replies stop at maxCommentDepth
+func (n commentNode) CanReply() bool {
+	return n.Page.IsAuthenticated && !n.Deleted && n.Depth < maxCommentDepth
+}
+
+// Anchor names the line the comment is anchored to, with the file if the
+// snippet has more than one
+// Edits can leave the file gone, so that's checked too
+func (n commentNode) Anchor() string {
+	files := n.Page.Snippet.Files
+	if len(files) > 1 && n.File <= len(files) {
+		return fmt.Sprintf("%s line %d", fileName(files[n.File-1], n.File-1), n.Line)
+	}
+	return fmt.Sprintf("line %d", n.Line)
+}
+
+// ReplyNodes pairs the replies to the comment with the page data
+func (n commentNode) ReplyNodes() []commentNode {
+	return newCommentNodes(n.Replies, n.Page, n.Depth+1)
+}
+
+// Pair each of the comments at depth with the page data
+func newCommentNodes(comments []*models.Comment, page *templateData, depth int) []commentNode {
+	nodes := make([]commentNode, len(comments))
+	for i, c := range comments {
+		nodes[i] = commentNode{Comment: c, Page: page, Depth: depth}
+	}
+	return nodes
+}
+
+// Pair each of the top level comments with the page data
+func commentNodes(comments []*models.Comment, page *templateData) []commentNode {
+	return newCommentNodes(comments, page, 1)
+}
+
 // Init template.FuncMap object and store it in a global variable
 var functions = template.FuncMap{
-	"humanDate": humanDate,
+	"humanDate":          humanDate,
+	"highlight":          highlightQuery,
+	"excerpt":            excerpt,
+	"highlightCode":      highlightCode,
+	"highlightFile":      highlightFile,
+	"lineID":             lineID,
+	"commentNodes":       commentNodes,
+	"languageName":       highlight.LanguageName,
+	"diffClass":          diffClass,
+	"revisionChangesURL": revisionChangesURL,
+	"fileBlocks":         fileBlocks,
+	"blankFileBlock":     blankFileBlock,
+	"fileName":           fileName,
+	"filePosition":       filePosition,
+	"snippetFileURL":     snippetFileURL,
+	"tagURL":             tagURL,
 }
 
 func newTemplateCache() (map[string]*template.Template, error) {
 	// Init new map to act as the cache
 	cache := map[string]*template.Template{}
 
-	// Use filepath.Glob() to get a slice of all filepaths that match
-	// The pattern "./ui/html/pages/*.tmpl.html"
-	pages, err := filepath.Glob("./ui/html/pages/*.tmpl.html")
+	// Use fs.Glob() to get a slice of all filepaths in the embedded
+	// filesystem that match the pattern "html/pages/*.tmpl.html"
+	pages, err := fs.Glob(ui.Files, "html/pages/*.tmpl.html")
 	if err != nil {
 		return nil, err
 	}
@@ -45,22 +240,17 @@ func newTemplateCache() (map[string]*template.Template, error) {
 		// Extract the file name and assign it to name variable
 		name := filepath.Base(page)
 
-		// Parse the base template file into a template set
-		// template.FuncMap must register with template set before calling
-		// ParseFiles()
-		ts, err := template.New(name).Funcs(functions).ParseFiles("./ui/html/base.tmpl.html")
-		if err != nil {
-			return nil, err
-		}
-
-		// Call ParseGlob() on any partials
-		ts, err = ts.ParseGlob("./ui/html/partials/*.tmpl.html")
-		if err != nil {
-			return nil, err
+		// Base template, any partials, then the page template
+		patterns := []string{
+			"html/base.tmpl.html",
+			"html/partials/*.tmpl.html",
+			page,
 		}
 
-		// Call ParseFiles() on page template
-		ts, err = ts.ParseFiles(page)
+		// Parse the files into a template set
+		// template.FuncMap must register with template set before calling
+		// ParseFS()
+		ts, err := template.New(name).Funcs(functions).ParseFS(ui.Files, patterns...)
 		if err != nil {
 			return nil, err
 		}
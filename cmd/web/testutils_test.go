@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+	"github.com/koller-m/snippetbox/internal/models/memory"
+
+	"github.com/alexedwards/scs/v2"
+	"github.com/go-playground/form/v4"
+)
+
+// Create a new application backed by the in-memory models
+// Sessions use the scs default in-memory store
+func newTestApplication(t *testing.T) *application {
+	templateCache, err := newTemplateCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sessionManager := scs.New()
+	sessionManager.Lifetime = 12 * time.Hour
+	sessionManager.Cookie.Secure = true
+
+	db := memory.New()
+
+	return &application{
+		errorLog:       log.New(io.Discard, "", 0),
+		infoLog:        log.New(io.Discard, "", 0),
+		snippets:       &memory.SnippetModel{DB: db},
+		users:          &memory.UserModel{DB: db},
+		tokens:         &memory.TokenModel{DB: db},
+		collections:    &memory.CollectionModel{DB: db},
+		stars:          &memory.StarModel{DB: db},
+		comments:       &memory.CommentModel{DB: db},
+		templateCache:  templateCache,
+		formDecoder:    form.NewDecoder(),
+		sessionManager: sessionManager,
+	}
+}
+
+// Define testServer type which embeds httptest.Server
+type testServer struct {
+	*httptest.Server
+}
+
+// Create a new test server with a cookie jar, so the session and CSRF
+// cookies are sent on later requests
+func newTestServer(t *testing.T, h http.Handler) *testServer {
+	ts := httptest.NewTLSServer(h)
+	t.Cleanup(ts.Close)
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.Client().Jar = jar
+
+	// Don't follow redirects, so the tests can check the 3xx responses
+	ts.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	return &testServer{ts}
+}
+
+// Make a GET request to the test server
+// Returns the status code, headers and body
+func (ts *testServer) get(t *testing.T, urlPath string) (int, http.Header, string) {
+	rs, err := ts.Client().Get(ts.URL + urlPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// Make a POST request with url-encoded form data to the test server
+func (ts *testServer) postForm(t *testing.T, urlPath string, form url.Values) (int, http.Header, string) {
+	rs, err := ts.Client().PostForm(ts.URL+urlPath, form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+// Send a request built by the test to the test server, for methods and
+// headers get and postForm don't cover
+// Returns the status code, headers and body
+func (ts *testServer) do(t *testing.T, req *http.Request) (int, http.Header, string) {
+	rs, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rs.Body.Close()
+
+	body, err := io.ReadAll(rs.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	return rs.StatusCode, rs.Header, string(body)
+}
+
+var csrfTokenRX = regexp.MustCompile(`<input type="hidden" name="csrf_token" value="(.+)">`)
+
+// Pull the CSRF token out of a rendered form
+func extractCSRFToken(t *testing.T, body string) string {
+	matches := csrfTokenRX.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		t.Fatal("no csrf token found in body")
+	}
+
+	return html.UnescapeString(matches[1])
+}
+
+// Sign up and log in a user through the handlers, so the test server's
+// cookie jar holds an authenticated session
+func (ts *testServer) login(t *testing.T, app *application, name, email, password string) {
+	err := app.users.Insert(name, email, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, body := ts.get(t, "/user/login")
+
+	form := url.Values{}
+	form.Add("email", email)
+	form.Add("password", password)
+	form.Add("csrf_token", extractCSRFToken(t, body))
+
+	code, _, _ := ts.postForm(t, "/user/login", form)
+	if code != http.StatusSeeOther {
+		t.Fatalf("login: want %d; got %d", http.StatusSeeOther, code)
+	}
+}
+
+// Return the time days from now, for a snippet's expiry
+func inDays(days int) *time.Time {
+	t := time.Now().AddDate(0, 0, days)
+	return &t
+}
+
+// Return the files of a snippet with a single unnamed file
+func oneFile(language, content string) []*models.SnippetFile {
+	return []*models.SnippetFile{{Language: language, Content: content}}
+}
@@ -3,12 +3,16 @@ module github.com/koller-m/snippetbox
 go 1.18
 
 require (
+	github.com/alecthomas/chroma/v2 v2.2.0 // indirect
 	github.com/alexedwards/scs/mysqlstore v0.0.0-20220528130143-d93ace5be94b // indirect
+	github.com/alexedwards/scs/sqlite3store v0.0.0-20220528130143-d93ace5be94b // indirect
 	github.com/alexedwards/scs/v2 v2.5.0 // indirect
+	github.com/dlclark/regexp2 v1.4.0 // indirect
 	github.com/go-playground/form/v4 v4.2.0 // indirect
 	github.com/go-sql-driver/mysql v1.6.0 // indirect
 	github.com/julienschmidt/httprouter v1.3.0 // indirect
 	github.com/justinas/alice v1.2.0 // indirect
 	github.com/justinas/nosurf v1.1.1 // indirect
+	github.com/mattn/go-sqlite3 v1.14.16 // indirect
 	golang.org/x/crypto v0.0.0-20220817201139-bc19a97f63c8 // indirect
 )
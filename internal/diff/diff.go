@@ -0,0 +1,307 @@
+// Package diff compares two texts line by line and groups the changes into
+// unified diff hunks
+//
+// Lines are compared with their line endings removed, so content saved
+// from a textarea with CRLF line endings compares equal to the same
+// content with LF line endings
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Define Op type for what happened to a line
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Define Line type for one line of a diff
+// OldNum and NewNum are the 1-based line numbers in the old and new text,
+// and 0 for the side a line isn't in
+type Line struct {
+	Op     Op
+	Text   string
+	OldNum int
+	NewNum int
+}
+
+// Prefix returns the character that starts the line in a unified diff
+func (l Line) Prefix() string {
+	switch l.Op {
+	case Delete:
+		return "-"
+	case Insert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// Define Hunk type for a run of changes and the context around them
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	Lines    []Line
+}
+
+// Header returns the hunk's "@@ -1,3 +1,4 @@" line
+// As in GNU diff, a count of 1 is left out, and an empty range starts at
+// the line before it
+func (h Hunk) Header() string {
+	return fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+}
+
+func hunkRange(start, lines int) string {
+	if lines == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, lines)
+}
+
+// Beyond this many inserted and deleted lines, finding the shortest edit
+// gets too slow, so whatever is left is replaced wholesale
+const maxEdits = 1000
+
+// Hunks returns the changes between old and new, with up to context
+// unchanged lines either side of each change
+// Changes closer together than twice the context share a hunk
+// Returns nil if the texts have the same lines
+func Hunks(old, new string, context int) []Hunk {
+	lines := Lines(old, new)
+
+	var hunks []Hunk
+	oldNum, newNum := 0, 0
+	pos := 0
+
+	for i := 0; i < len(lines); {
+		if lines[i].Op == Equal {
+			i++
+			continue
+		}
+
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+
+		// Take in the following changes until the unchanged run between
+		// two of them is too long to show in full
+		end := i
+		for {
+			for end < len(lines) && lines[end].Op != Equal {
+				end++
+			}
+
+			next := end
+			for next < len(lines) && lines[next].Op == Equal {
+				next++
+			}
+
+			if next < len(lines) && next-end <= 2*context {
+				end = next
+				continue
+			}
+
+			end += context
+			if end > next {
+				end = next
+			}
+			break
+		}
+
+		// Count the lines before the hunk to find where it starts
+		for ; pos < start; pos++ {
+			if lines[pos].Op != Insert {
+				oldNum++
+			}
+			if lines[pos].Op != Delete {
+				newNum++
+			}
+		}
+
+		h := Hunk{Lines: lines[start:end]}
+		for _, l := range h.Lines {
+			if l.Op != Insert {
+				h.OldLines++
+			}
+			if l.Op != Delete {
+				h.NewLines++
+			}
+		}
+
+		h.OldStart, h.NewStart = oldNum, newNum
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+
+		hunks = append(hunks, h)
+		i = end
+	}
+
+	return hunks
+}
+
+// Lines returns every line of old and new in order, marked with whether
+// it was kept, deleted or inserted
+// The edit is a shortest one, found with Myers' algorithm
+func Lines(old, new string) []Line {
+	a, b := splitLines(old), splitLines(new)
+
+	// Lines shared at the start and end don't need to go through the
+	// search, which keeps it fast for the usual small edit
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]Line, 0, len(a)+len(b))
+
+	for i := 0; i < prefix; i++ {
+		lines = append(lines, Line{Op: Equal, Text: a[i], OldNum: i + 1, NewNum: i + 1})
+	}
+
+	for _, l := range shortestEdit(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		if l.OldNum > 0 {
+			l.OldNum += prefix
+		}
+		if l.NewNum > 0 {
+			l.NewNum += prefix
+		}
+		lines = append(lines, l)
+	}
+
+	for i := suffix; i > 0; i-- {
+		oldIndex, newIndex := len(a)-i, len(b)-i
+		lines = append(lines, Line{Op: Equal, Text: a[oldIndex], OldNum: oldIndex + 1, NewNum: newIndex + 1})
+	}
+
+	return lines
+}
+
+// Split text into lines without their line endings
+// A final line ending doesn't start another line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSuffix(l, "\r")
+	}
+	return lines
+}
+
+// Find a shortest edit turning a into b
+// For each number of edits d, v holds the furthest x reached on each
+// diagonal k = x - y. A copy is kept for every d so the path can be traced
+// back from the end
+func shortestEdit(a, b []string) []Line {
+	n, m := len(a), len(b)
+
+	limit := n + m
+	if limit > maxEdits {
+		limit = maxEdits
+	}
+
+	// Diagonals run from -limit to limit, so index them with offset
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, offset)
+			}
+		}
+	}
+
+	return replaceAll(a, b)
+}
+
+// Walk back through trace from the end of a and b to build the edit
+func backtrack(a, b []string, trace [][]int, offset int) []Line {
+	x, y := len(a), len(b)
+	var reversed []Line
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, Line{Op: Equal, Text: a[x-1], OldNum: x, NewNum: y})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, Line{Op: Insert, Text: b[y-1], NewNum: y})
+			} else {
+				reversed = append(reversed, Line{Op: Delete, Text: a[x-1], OldNum: x})
+			}
+		}
+
+		x, y = prevX, prevY
+	}
+
+	lines := make([]Line, len(reversed))
+	for i, l := range reversed {
+		lines[len(reversed)-1-i] = l
+	}
+	return lines
+}
+
+// The edit used when the texts are too different to search: delete every
+// line of a and insert every line of b
+func replaceAll(a, b []string) []Line {
+	lines := make([]Line, 0, len(a)+len(b))
+	for i, text := range a {
+		lines = append(lines, Line{Op: Delete, Text: text, OldNum: i + 1})
+	}
+	for i, text := range b {
+		lines = append(lines, Line{Op: Insert, Text: text, NewNum: i + 1})
+	}
+	return lines
+}
@@ -0,0 +1,116 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// Render hunks in unified diff format, for comparing with the tests
+func unified(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		b.WriteString(h.Header() + "\n")
+		for _, l := range h.Lines {
+			b.WriteString(l.Prefix() + l.Text + "\n")
+		}
+	}
+	return b.String()
+}
+
+func TestHunks(t *testing.T) {
+	tests := []struct {
+		name string
+		old  string
+		new  string
+		want string
+	}{
+		{
+			name: "Same",
+			old:  "a\nb\nc\n",
+			new:  "a\nb\nc\n",
+			want: "",
+		},
+		{
+			name: "Line endings",
+			old:  "a\r\nb\r\n",
+			new:  "a\nb",
+			want: "",
+		},
+		{
+			name: "Changed line",
+			old:  "a\nb\nc\n",
+			new:  "a\nB\nc\n",
+			want: "@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		{
+			name: "From empty",
+			old:  "",
+			new:  "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "To empty",
+			old:  "a\n",
+			new:  "",
+			want: "@@ -1 +0,0 @@\n-a\n",
+		},
+		{
+			name: "Separate hunks",
+			old:  "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n",
+			new:  "one\n2\n3\n4\n5\n6\n7\n8\n9\nten\n",
+			want: "@@ -1,2 +1,2 @@\n-1\n+one\n 2\n@@ -9,2 +9,2 @@\n 9\n-10\n+ten\n",
+		},
+		{
+			name: "Joined hunks",
+			old:  "1\n2\n3\n4\n5\n",
+			new:  "one\n2\n3\nfour\n5\n",
+			want: "@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n-4\n+four\n 5\n",
+		},
+		{
+			name: "Insert in the middle",
+			old:  "a\nb\nc\nd\n",
+			new:  "a\nb\nx\nc\nd\n",
+			want: "@@ -2,2 +2,3 @@\n b\n+x\n c\n",
+		},
+		{
+			name: "Moved line",
+			old:  "a\nb\nc\n",
+			new:  "b\nc\na\n",
+			want: "@@ -1,3 +1,3 @@\n-a\n b\n c\n+a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			context := 1
+			got := unified(Hunks(tt.old, tt.new, context))
+
+			if got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLinesTooManyEdits(t *testing.T) {
+	var old, new strings.Builder
+	for i := 0; i < maxEdits; i++ {
+		fmt.Fprintf(&old, "old %d\n", i)
+		fmt.Fprintf(&new, "new %d\n", i)
+	}
+
+	lines := Lines(old.String(), new.String())
+
+	if len(lines) != 2*maxEdits {
+		t.Fatalf("want %d lines; got %d", 2*maxEdits, len(lines))
+	}
+
+	// Every line is still accounted for, with its line number
+	if l := lines[0]; l.Op != Delete || l.OldNum != 1 {
+		t.Errorf("want first line deleted from line 1; got %+v", l)
+	}
+	if l := lines[len(lines)-1]; l.Op != Insert || l.NewNum != maxEdits {
+		t.Errorf("want last line inserted at line %d; got %+v", maxEdits, l)
+	}
+}
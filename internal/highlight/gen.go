@@ -0,0 +1,25 @@
+//go:build ignore
+
+// Writes the stylesheet for the highlighted HTML to ui/static/css
+// Run with go generate in internal/highlight
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/koller-m/snippetbox/internal/highlight"
+)
+
+func main() {
+	f, err := os.Create("../../ui/static/css/highlight.css")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	err = highlight.WriteCSS(f)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package highlight renders snippet content as syntax highlighted HTML
+//
+// Highlighting happens on the server and the output only uses CSS classes,
+// so it works with the strict Content-Security-Policy set by secureHeaders
+// The matching stylesheet is ui/static/css/highlight.css, which is
+// generated by running go generate in this package
+package highlight
+
+//go:generate go run gen.go
+
+import (
+	"bytes"
+	"html/template"
+	"io"
+	"regexp"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Define Language type for an entry in the language picker
+// ID is stored on the snippet and is also the chroma lexer name
+// Extension is used for download filenames
+type Language struct {
+	ID        string
+	Name      string
+	Extension string
+}
+
+// DefaultLanguage is used when a snippet doesn't say what it contains
+const DefaultLanguage = "plaintext"
+
+// Languages lists the languages snippets can be highlighted as
+var Languages = []Language{
+	{DefaultLanguage, "Plain text", "txt"},
+	{"bash", "Bash", "sh"},
+	{"c", "C", "c"},
+	{"cpp", "C++", "cpp"},
+	{"csharp", "C#", "cs"},
+	{"css", "CSS", "css"},
+	{"diff", "Diff", "diff"},
+	{"dockerfile", "Dockerfile", "dockerfile"},
+	{"go", "Go", "go"},
+	{"html", "HTML", "html"},
+	{"java", "Java", "java"},
+	{"javascript", "JavaScript", "js"},
+	{"json", "JSON", "json"},
+	{"kotlin", "Kotlin", "kt"},
+	{"lua", "Lua", "lua"},
+	{"makefile", "Makefile", "mk"},
+	{"markdown", "Markdown", "md"},
+	{"php", "PHP", "php"},
+	{"python", "Python", "py"},
+	{"ruby", "Ruby", "rb"},
+	{"rust", "Rust", "rs"},
+	{"sql", "SQL", "sql"},
+	{"swift", "Swift", "swift"},
+	{"toml", "TOML", "toml"},
+	{"typescript", "TypeScript", "ts"},
+	{"yaml", "YAML", "yaml"},
+}
+
+// LanguageIDs returns the ID of every language, for validation
+func LanguageIDs() []string {
+	ids := make([]string, len(Languages))
+	for i, l := range Languages {
+		ids[i] = l.ID
+	}
+	return ids
+}
+
+// LanguageName returns the display name for a language ID
+// Unknown IDs are returned unchanged
+func LanguageName(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Name
+		}
+	}
+	return id
+}
+
+// Extension returns the file extension for a language ID
+// Unknown IDs get the plain text extension
+func Extension(id string) string {
+	for _, l := range Languages {
+		if l.ID == id {
+			return l.Extension
+		}
+	}
+	return "txt"
+}
+
+// Style used for both the HTML and the generated stylesheet
+const styleName = "github"
+
+// Classes instead of inline styles, with a number at the start of each line
+var formatter = html.New(html.WithClasses(true), html.WithLineNumbers(true))
+
+// Code returns content highlighted as the language with the given ID
+// The output is a complete <pre> element
+func Code(content, language string) (template.HTML, error) {
+	lexer := lexers.Get(language)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	lexer = chroma.Coalesce(lexer)
+
+	iterator, err := lexer.Tokenise(nil, content)
+	if err != nil {
+		return "", err
+	}
+
+	buf := new(bytes.Buffer)
+
+	err = formatter.Format(buf, styles.Get(styleName), iterator)
+	if err != nil {
+		return "", err
+	}
+
+	// The formatter escapes the content, so this is safe to mark as HTML
+	return template.HTML(buf.String()), nil
+}
+
+// Matches the line number at the start of each line of the formatter output
+var lineNumberRX = regexp.MustCompile(`<span class="ln">( *)([0-9]+)</span>`)
+
+// CodeWithLineIDs is like Code, but gives each line number an id made of
+// prefix and the number, so links can point at a single line
+// The formatter's own linkable line numbers come with inline styles, which
+// the CSP would block
+// The prefix is written into the HTML as is, so must be safe in an attribute
+func CodeWithLineIDs(content, language, prefix string) (template.HTML, error) {
+	code, err := Code(content, language)
+	if err != nil {
+		return "", err
+	}
+
+	html := lineNumberRX.ReplaceAllString(string(code), `<span class="ln" id="`+prefix+`$2">$1$2</span>`)
+	return template.HTML(html), nil
+}
+
+// WriteCSS writes the stylesheet for the classes used by Code()
+func WriteCSS(w io.Writer) error {
+	return formatter.WriteCSS(w, styles.Get(styleName))
+}
@@ -0,0 +1,58 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+func TestLanguagesHaveLexers(t *testing.T) {
+	for _, l := range Languages {
+		if lexers.Get(l.ID) == nil {
+			t.Errorf("no lexer for language %q", l.ID)
+		}
+	}
+}
+
+func TestCode(t *testing.T) {
+	code, err := Code("package main\n\nvar s = \"<script>\"\n", "go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(code)
+
+	if strings.Contains(html, "<script>") {
+		t.Error("want content to be escaped")
+	}
+
+	// Only classes, never inline styles, which the CSP would block
+	if strings.Contains(html, "style=") {
+		t.Error("want no inline styles")
+	}
+
+	if !strings.Contains(html, `<span class="ln">3</span>`) {
+		t.Error("want line numbers")
+	}
+}
+
+func TestCodeWithLineIDs(t *testing.T) {
+	code, err := CodeWithLineIDs("a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n", "plaintext", "f2-L")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := string(code)
+
+	// Line numbers are padded to the same width
+	for _, want := range []string{`<span class="ln" id="f2-L1"> 1</span>`, `<span class="ln" id="f2-L10">10</span>`} {
+		if !strings.Contains(html, want) {
+			t.Errorf("want %q in %q", want, html)
+		}
+	}
+
+	if strings.Contains(html, "style=") {
+		t.Error("want no inline styles")
+	}
+}
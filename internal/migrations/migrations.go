@@ -0,0 +1,266 @@
+// Package migrations holds the versioned database schema for each driver
+// and applies it, recording the applied versions in schema_migrations
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql
+// and live in a directory per driver
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed mysql/*.sql sqlite/*.sql
+var files embed.FS
+
+// ErrNoChange is returned by Down() when there is nothing to roll back
+var ErrNoChange = errors.New("migrations: no migrations to roll back")
+
+// Define Migration type for a single schema change
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Define Status type to report whether a migration has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// Define Migrator type which wraps a db connection pool
+// Dialect picks the directory of migrations, "mysql" or "sqlite"
+type Migrator struct {
+	DB      *sql.DB
+	Dialect string
+}
+
+// Table recording the applied versions
+// The same statement works on MySQL and SQLite
+const createSchemaTable = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER NOT NULL PRIMARY KEY,
+    applied DATETIME NOT NULL
+)`
+
+// Load the migrations for the dialect, sorted by version
+func (m *Migrator) migrations() ([]Migration, error) {
+	names, err := fs.Glob(files, m.Dialect+"/*.sql")
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("migrations: no migrations for dialect %q", m.Dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+
+	for _, name := range names {
+		// E.g. "0001_create_users.up.sql"
+		base := path.Base(name)
+
+		versionPart, rest, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migrations: bad file name %q", base)
+		}
+
+		version, err := strconv.Atoi(versionPart)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: bad version in %q", base)
+		}
+
+		body, err := fs.ReadFile(files, name)
+		if err != nil {
+			return nil, err
+		}
+
+		mg, ok := byVersion[version]
+		if !ok {
+			mg = &Migration{Version: version}
+			byVersion[version] = mg
+		}
+
+		switch {
+		case strings.HasSuffix(rest, ".up.sql"):
+			mg.Name = strings.TrimSuffix(rest, ".up.sql")
+			mg.Up = string(body)
+		case strings.HasSuffix(rest, ".down.sql"):
+			mg.Down = string(body)
+		default:
+			return nil, fmt.Errorf("migrations: %q is neither up nor down", base)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mg := range byVersion {
+		if mg.Up == "" || mg.Down == "" {
+			return nil, fmt.Errorf("migrations: version %d needs both up and down files", mg.Version)
+		}
+		migrations = append(migrations, *mg)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// Return the applied versions and when they were applied
+func (m *Migrator) applied() (map[int]time.Time, error) {
+	if _, err := m.DB.Exec(createSchemaTable); err != nil {
+		return nil, err
+	}
+
+	rows, err := m.DB.Query("SELECT version, applied FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err = rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+// Status lists every migration and whether it has been applied
+func (m *Migrator) Status() ([]Status, error) {
+	migrations, err := m.migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(migrations))
+	for _, mg := range migrations {
+		at, ok := applied[mg.Version]
+		statuses = append(statuses, Status{Migration: mg, Applied: ok, AppliedAt: at})
+	}
+
+	return statuses, nil
+}
+
+// Up applies every pending migration in version order
+// Returns the migrations that were applied
+func (m *Migrator) Up() ([]Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+
+	for _, s := range statuses {
+		if s.Applied {
+			continue
+		}
+
+		err = m.run(s.Up, "INSERT INTO schema_migrations (version, applied) VALUES (?, ?)", s.Version, time.Now().UTC())
+		if err != nil {
+			return done, fmt.Errorf("migrations: applying %04d_%s: %w", s.Version, s.Name, err)
+		}
+
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// Down rolls back the most recently applied migration
+// Returns ErrNoChange if nothing has been applied
+func (m *Migrator) Down() (Migration, error) {
+	statuses, err := m.Status()
+	if err != nil {
+		return Migration{}, err
+	}
+
+	for i := len(statuses) - 1; i >= 0; i-- {
+		s := statuses[i]
+		if !s.Applied {
+			continue
+		}
+
+		err = m.run(s.Down, "DELETE FROM schema_migrations WHERE version = ?", s.Version)
+		if err != nil {
+			return Migration{}, fmt.Errorf("migrations: rolling back %04d_%s: %w", s.Version, s.Name, err)
+		}
+
+		return s.Migration, nil
+	}
+
+	return Migration{}, ErrNoChange
+}
+
+// Run the statements in script and then record the change in a transaction
+// MySQL commits DDL implicitly, so on MySQL a failure part way through a
+// script can leave earlier statements applied
+func (m *Migrator) run(script string, record string, args ...any) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range splitStatements(script) {
+		if _, err = tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+
+	if _, err = tx.Exec(record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Split a script into statements on semicolons at the end of a line
+// This avoids needing multiStatements=true in the MySQL DSN
+func splitStatements(script string) []string {
+	stmts := []string{}
+
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(strings.TrimSpace(line), ";") {
+			if stmt := strings.TrimSpace(current.String()); stmt != ";" {
+				stmts = append(stmts, stmt)
+			}
+			current.Reset()
+		}
+	}
+
+	if stmt := strings.TrimSpace(current.String()); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+
+	return stmts
+}
@@ -0,0 +1 @@
+ALTER TABLE snippets ADD COLUMN language VARCHAR(30) NOT NULL DEFAULT 'plaintext';
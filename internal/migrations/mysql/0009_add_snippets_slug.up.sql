@@ -0,0 +1,8 @@
+-- Slugs are compared byte for byte, so slugs differing only in case are distinct
+ALTER TABLE snippets ADD COLUMN slug VARBINARY(16);
+
+UPDATE snippets SET slug = LEFT(SHA2(CONCAT(id, RAND(), UUID()), 256), 16);
+
+ALTER TABLE snippets MODIFY slug VARBINARY(16) NOT NULL;
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
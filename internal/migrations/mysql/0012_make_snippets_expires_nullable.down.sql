@@ -0,0 +1,4 @@
+-- Snippets that never expire get a far future expiry instead
+UPDATE snippets SET expires = DATE_ADD(created, INTERVAL 100 YEAR) WHERE expires IS NULL;
+
+ALTER TABLE snippets MODIFY expires DATETIME NOT NULL;
@@ -0,0 +1,2 @@
+-- A NULL expiry means the snippet never expires
+ALTER TABLE snippets MODIFY expires DATETIME NULL;
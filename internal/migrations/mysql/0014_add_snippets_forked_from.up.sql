@@ -0,0 +1,4 @@
+-- Forks keep existing when the original is deleted, they just lose the link
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER NULL;
+
+ALTER TABLE snippets ADD CONSTRAINT fk_snippets_forked_from FOREIGN KEY (forked_from) REFERENCES snippets (id) ON DELETE SET NULL;
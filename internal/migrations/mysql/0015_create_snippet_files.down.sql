@@ -0,0 +1,28 @@
+-- Only the first file of each snippet and revision is kept
+ALTER TABLE snippets DROP INDEX idx_snippets_fulltext;
+
+ALTER TABLE snippets ADD COLUMN content TEXT NULL;
+
+ALTER TABLE snippets ADD COLUMN language VARCHAR(30) NOT NULL DEFAULT 'plaintext';
+
+UPDATE snippets s INNER JOIN snippet_files f ON f.snippet_id = s.id AND f.position = 1
+SET s.content = f.content, s.language = f.language;
+
+UPDATE snippets SET content = '' WHERE content IS NULL;
+
+ALTER TABLE snippets MODIFY content TEXT NOT NULL;
+
+ALTER TABLE snippets ADD FULLTEXT INDEX idx_snippets_fulltext (title, content);
+
+ALTER TABLE snippet_revisions ADD COLUMN content TEXT NULL;
+
+UPDATE snippet_revisions r INNER JOIN snippet_revision_files f ON f.revision_id = r.id AND f.position = 1
+SET r.content = f.content;
+
+UPDATE snippet_revisions SET content = '' WHERE content IS NULL;
+
+ALTER TABLE snippet_revisions MODIFY content TEXT NOT NULL;
+
+DROP TABLE snippet_revision_files;
+
+DROP TABLE snippet_files;
@@ -0,0 +1,43 @@
+-- Snippets hold one or more files, and so do their revisions
+CREATE TABLE snippet_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    snippet_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    filename VARCHAR(100) NOT NULL,
+    language VARCHAR(30) NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position),
+    CONSTRAINT fk_snippet_files_snippet FOREIGN KEY (snippet_id) REFERENCES snippets (id) ON DELETE CASCADE
+);
+
+CREATE TABLE snippet_revision_files (
+    id INTEGER NOT NULL PRIMARY KEY AUTO_INCREMENT,
+    revision_id INTEGER NOT NULL,
+    position INTEGER NOT NULL,
+    filename VARCHAR(100) NOT NULL,
+    language VARCHAR(30) NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position),
+    CONSTRAINT fk_snippet_revision_files_revision FOREIGN KEY (revision_id) REFERENCES snippet_revisions (id) ON DELETE CASCADE
+);
+
+-- Existing snippets become a single unnamed file
+INSERT INTO snippet_files (snippet_id, position, filename, language, content)
+SELECT id, 1, '', language, content FROM snippets;
+
+-- Revisions didn't record the language, so they take the current one
+INSERT INTO snippet_revision_files (revision_id, position, filename, language, content)
+SELECT r.id, 1, '', s.language, r.content FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id;
+
+ALTER TABLE snippet_revisions DROP COLUMN content;
+
+-- Search now matches titles and file contents separately
+ALTER TABLE snippets DROP INDEX idx_snippets_fulltext;
+
+ALTER TABLE snippets DROP COLUMN content;
+
+ALTER TABLE snippets DROP COLUMN language;
+
+ALTER TABLE snippets ADD FULLTEXT INDEX idx_snippets_fulltext (title);
+
+ALTER TABLE snippet_files ADD FULLTEXT INDEX idx_snippet_files_fulltext (content);
@@ -0,0 +1,8 @@
+CREATE TABLE tokens (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    hash BLOB NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT tokens_uc_hash UNIQUE (hash)
+);
@@ -0,0 +1 @@
+-- SQLite searches with LIKE, so there is no index to drop
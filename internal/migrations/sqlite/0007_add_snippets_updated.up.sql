@@ -0,0 +1,5 @@
+-- SQLite can only add a NOT NULL column with a default, so the existing
+-- rows are backfilled from created afterwards
+ALTER TABLE snippets ADD COLUMN updated DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00';
+
+UPDATE snippets SET updated = created;
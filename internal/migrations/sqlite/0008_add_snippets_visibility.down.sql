@@ -0,0 +1 @@
+ALTER TABLE snippets DROP COLUMN visibility;
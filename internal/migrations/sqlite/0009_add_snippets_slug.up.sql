@@ -0,0 +1,7 @@
+-- SQLite can't add a NOT NULL column without a default, but every insert
+-- sets the slug
+ALTER TABLE snippets ADD COLUMN slug TEXT;
+
+UPDATE snippets SET slug = lower(hex(randomblob(8)));
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
@@ -0,0 +1,27 @@
+-- A NULL expiry means the snippet never expires
+-- SQLite can't drop NOT NULL from a column, so the table is rebuilt
+CREATE TABLE snippets_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    expires DATETIME,
+    language TEXT NOT NULL DEFAULT 'plaintext',
+    updated DATETIME NOT NULL DEFAULT '1970-01-01 00:00:00',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    slug TEXT,
+    burn_after_reading BOOLEAN NOT NULL DEFAULT FALSE,
+    hashed_password TEXT
+);
+
+INSERT INTO snippets_new (id, user_id, title, content, created, expires, language, updated, visibility, slug, burn_after_reading, hashed_password)
+SELECT id, user_id, title, content, created, expires, language, updated, visibility, slug, burn_after_reading, hashed_password FROM snippets;
+
+DROP TABLE snippets;
+
+ALTER TABLE snippets_new RENAME TO snippets;
+
+CREATE INDEX idx_snippets_created ON snippets (created);
+
+CREATE UNIQUE INDEX idx_snippets_slug ON snippets (slug);
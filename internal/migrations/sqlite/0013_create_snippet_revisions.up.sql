@@ -0,0 +1,14 @@
+CREATE TABLE snippet_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    revision INTEGER NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created DATETIME NOT NULL,
+    CONSTRAINT snippet_revisions_uc_revision UNIQUE (snippet_id, revision)
+);
+
+-- Existing snippets start their history at how they are now
+INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, content, created)
+SELECT id, 1, user_id, title, content, updated FROM snippets;
@@ -0,0 +1,4 @@
+-- Forks keep existing when the original is deleted, they just lose the link
+ALTER TABLE snippets ADD COLUMN forked_from INTEGER REFERENCES snippets (id) ON DELETE SET NULL;
+
+CREATE INDEX idx_snippets_forked_from ON snippets (forked_from);
@@ -0,0 +1,18 @@
+-- Only the first file of each snippet and revision is kept
+-- SQLite can only add a NOT NULL column with a default
+ALTER TABLE snippets ADD COLUMN content TEXT NOT NULL DEFAULT '';
+
+ALTER TABLE snippets ADD COLUMN language TEXT NOT NULL DEFAULT 'plaintext';
+
+UPDATE snippets SET
+    content = COALESCE((SELECT f.content FROM snippet_files f WHERE f.snippet_id = snippets.id AND f.position = 1), ''),
+    language = COALESCE((SELECT f.language FROM snippet_files f WHERE f.snippet_id = snippets.id AND f.position = 1), 'plaintext');
+
+ALTER TABLE snippet_revisions ADD COLUMN content TEXT NOT NULL DEFAULT '';
+
+UPDATE snippet_revisions SET
+    content = COALESCE((SELECT f.content FROM snippet_revision_files f WHERE f.revision_id = snippet_revisions.id AND f.position = 1), '');
+
+DROP TABLE snippet_revision_files;
+
+DROP TABLE snippet_files;
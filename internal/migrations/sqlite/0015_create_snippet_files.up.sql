@@ -0,0 +1,34 @@
+-- Snippets hold one or more files, and so do their revisions
+CREATE TABLE snippet_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_files_uc_position UNIQUE (snippet_id, position)
+);
+
+CREATE TABLE snippet_revision_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    revision_id INTEGER NOT NULL REFERENCES snippet_revisions (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    filename TEXT NOT NULL,
+    language TEXT NOT NULL DEFAULT 'plaintext',
+    content TEXT NOT NULL,
+    CONSTRAINT snippet_revision_files_uc_position UNIQUE (revision_id, position)
+);
+
+-- Existing snippets become a single unnamed file
+INSERT INTO snippet_files (snippet_id, position, filename, language, content)
+SELECT id, 1, '', language, content FROM snippets;
+
+-- Revisions didn't record the language, so they take the current one
+INSERT INTO snippet_revision_files (revision_id, position, filename, language, content)
+SELECT r.id, 1, '', s.language, r.content FROM snippet_revisions r INNER JOIN snippets s ON s.id = r.snippet_id;
+
+ALTER TABLE snippet_revisions DROP COLUMN content;
+
+ALTER TABLE snippets DROP COLUMN content;
+
+ALTER TABLE snippets DROP COLUMN language;
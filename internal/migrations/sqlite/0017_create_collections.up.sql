@@ -0,0 +1,22 @@
+-- Collections are named, ordered lists of their owner's snippets
+CREATE TABLE collections (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    visibility TEXT NOT NULL DEFAULT 'public',
+    created DATETIME NOT NULL,
+    CONSTRAINT collections_uc_slug UNIQUE (slug)
+);
+
+CREATE INDEX idx_collections_user_id ON collections (user_id);
+
+CREATE TABLE collection_snippets (
+    collection_id INTEGER NOT NULL REFERENCES collections (id) ON DELETE CASCADE,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    PRIMARY KEY (collection_id, snippet_id)
+);
+
+CREATE INDEX idx_collection_snippets_snippet_id ON collection_snippets (snippet_id);
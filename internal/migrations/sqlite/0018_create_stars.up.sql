@@ -0,0 +1,9 @@
+-- Each user can star a snippet once
+CREATE TABLE stars (
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    created DATETIME NOT NULL,
+    PRIMARY KEY (user_id, snippet_id)
+);
+
+CREATE INDEX idx_stars_snippet_id ON stars (snippet_id);
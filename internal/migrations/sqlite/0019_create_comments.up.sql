@@ -0,0 +1,16 @@
+-- Comments can reply to another comment on the same snippet
+-- file and line anchor a comment to a line of the snippet, where file is
+-- the 1-based position of the file, and both are 0 for no anchor
+CREATE TABLE comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    snippet_id INTEGER NOT NULL REFERENCES snippets (id) ON DELETE CASCADE,
+    parent_id INTEGER REFERENCES comments (id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    file INTEGER NOT NULL DEFAULT 0,
+    line INTEGER NOT NULL DEFAULT 0,
+    content TEXT NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    created DATETIME NOT NULL
+);
+
+CREATE INDEX idx_comments_snippet_id ON comments (snippet_id);
@@ -0,0 +1,315 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// Define Collection type for a user's named, ordered list of snippets
+// Slug is the random identifier used in URLs, like a snippet's
+// UserName is the owner's display name, joined in from the users table
+// Visibility is one of the Visibility constants and applies to the
+// collection itself, each of its snippets keeps its own
+// Snippets is how many unexpired snippets it holds
+type Collection struct {
+	ID          int
+	Slug        string
+	UserID      int
+	UserName    string
+	Name        string
+	Description string
+	Visibility  string
+	Created     time.Time
+	Snippets    int
+}
+
+// VisibleTo reports whether the user with the given ID can view the collection
+// A userID of 0 means nobody is logged in
+func (c *Collection) VisibleTo(userID int) bool {
+	return c.Visibility != VisibilityPrivate || (userID != 0 && c.UserID == userID)
+}
+
+// Define CollectionModelInterface for the methods a collection store provides
+// CollectionModel is the MySQL implementation
+// The methods that change a collection's snippets don't check who owns it,
+// so callers have to
+type CollectionModelInterface interface {
+	Insert(userID int, name, description, visibility string) (string, error)
+	Get(slug string) (*Collection, error)
+	ByUser(userID int) ([]*Collection, error)
+	Update(id, userID int, name, description, visibility string) error
+	Delete(id, userID int) error
+	Snippets(collectionID int) ([]*Snippet, error)
+	AddSnippet(collectionID, snippetID int) error
+	RemoveSnippet(collectionID, snippetID int) error
+	MoveSnippet(collectionID, snippetID int, up bool) error
+}
+
+// Define CollectionModel type which wraps db connection pool
+type CollectionModel struct {
+	DB *sql.DB
+}
+
+// This will create a new collection owned by userID
+// Returns the slug of the new collection
+func (m *CollectionModel) Insert(userID int, name, description, visibility string) (string, error) {
+	slug, err := GenerateSlug()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO collections (slug, user_id, name, description, visibility, created)
+	VALUES(?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, slug, userID, name, description, visibility)
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// This will return a specific collection based on its slug
+func (m *CollectionModel) Get(slug string) (*Collection, error) {
+	stmt := `SELECT c.id, c.slug, c.user_id, u.name, c.name, c.description, c.visibility, c.created,
+	(SELECT COUNT(*) FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = c.id AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP())) AS snippets
+	FROM collections c INNER JOIN users u ON u.id = c.user_id WHERE c.slug = ?`
+
+	c := &Collection{}
+
+	err := m.DB.QueryRow(stmt, slug).Scan(&c.ID, &c.Slug, &c.UserID, &c.UserName, &c.Name, &c.Description, &c.Visibility, &c.Created, &c.Snippets)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// This will return all the collections belonging to a user, sorted by name
+func (m *CollectionModel) ByUser(userID int) ([]*Collection, error) {
+	stmt := `SELECT c.id, c.slug, c.user_id, u.name, c.name, c.description, c.visibility, c.created,
+	(SELECT COUNT(*) FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = c.id AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP())) AS snippets
+	FROM collections c INNER JOIN users u ON u.id = c.user_id WHERE c.user_id = ? ORDER BY c.name, c.id`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []*Collection{}
+
+	for rows.Next() {
+		c := &Collection{}
+		err = rows.Scan(&c.ID, &c.Slug, &c.UserID, &c.UserName, &c.Name, &c.Description, &c.Visibility, &c.Created, &c.Snippets)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return collections, nil
+}
+
+// This will update the name, description and visibility of a collection
+// The user_id condition means only the owner can change it
+// Returns ErrNoRecord if there is no such collection for this user
+func (m *CollectionModel) Update(id, userID int, name, description, visibility string) error {
+	// RowsAffected() doesn't count rows the update left unchanged, so
+	// check the collection is there first
+	var count int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM collections WHERE id = ? AND user_id = ?`, id, userID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNoRecord
+	}
+
+	stmt := `UPDATE collections SET name = ?, description = ?, visibility = ? WHERE id = ? AND user_id = ?`
+
+	_, err = m.DB.Exec(stmt, name, description, visibility, id, userID)
+	return err
+}
+
+// This will delete a collection owned by userID
+// Its snippets are left alone
+// Returns ErrNoRecord if there is no such collection for this user
+func (m *CollectionModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM collections WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will return the unexpired snippets in a collection, in order
+// Visibility isn't checked, so callers have to leave out the snippets the
+// user can't see
+func (m *CollectionModel) Snippets(collectionID int) ([]*Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.updated, s.expires, s.user_id, u.name
+	FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id INNER JOIN users u ON u.id = s.user_id
+	WHERE cs.collection_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) ORDER BY cs.position, cs.snippet_id`
+
+	rows, err := m.DB.Query(stmt, collectionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.UserName)
+		if err != nil {
+			return nil, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, err
+	}
+	return snippets, nil
+}
+
+// This will add a snippet to the end of a collection
+// Returns ErrDuplicateSnippet if it's already in the collection
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	stmt := `INSERT INTO collection_snippets (collection_id, snippet_id, position)
+	SELECT ?, ?, COALESCE(MAX(position), 0) + 1 FROM collection_snippets WHERE collection_id = ?`
+
+	_, err := m.DB.Exec(stmt, collectionID, snippetID, collectionID)
+	if err != nil {
+		// A second row for the same snippet breaks the primary key
+		var mySQLError *mysql.MySQLError
+		if errors.As(err, &mySQLError) {
+			if mySQLError.Number == 1062 && strings.Contains(mySQLError.Message, "PRIMARY") {
+				return ErrDuplicateSnippet
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// This will take a snippet out of a collection
+// Returns ErrNoRecord if it isn't in the collection
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	stmt := `DELETE FROM collection_snippets WHERE collection_id = ? AND snippet_id = ?`
+
+	result, err := m.DB.Exec(stmt, collectionID, snippetID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will move a snippet one place up or down a collection, by swapping
+// positions with the unexpired snippet next to it
+// Moving the first snippet up or the last one down does nothing
+// Returns ErrNoRecord if it isn't in the collection
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var position int
+
+	err = tx.QueryRow(`SELECT position FROM collection_snippets WHERE collection_id = ? AND snippet_id = ? FOR UPDATE`, collectionID, snippetID).Scan(&position)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNoRecord
+		} else {
+			return err
+		}
+	}
+
+	stmt := `SELECT cs.snippet_id, cs.position FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+	WHERE cs.collection_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND cs.position > ? ORDER BY cs.position LIMIT 1 FOR UPDATE`
+	if up {
+		stmt = `SELECT cs.snippet_id, cs.position FROM collection_snippets cs INNER JOIN snippets s ON s.id = cs.snippet_id
+		WHERE cs.collection_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND cs.position < ? ORDER BY cs.position DESC LIMIT 1 FOR UPDATE`
+	}
+
+	var neighbourID, neighbourPosition int
+
+	err = tx.QueryRow(stmt, collectionID, position).Scan(&neighbourID, &neighbourPosition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		} else {
+			return err
+		}
+	}
+
+	stmt = `UPDATE collection_snippets SET position = ? WHERE collection_id = ? AND snippet_id = ?`
+
+	_, err = tx.Exec(stmt, neighbourPosition, collectionID, snippetID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(stmt, position, collectionID, neighbourID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// Define Comment type for a comment on a snippet
+// ParentID is the comment it replies to, or 0 for a top level comment
+// UserName is the author's display name, joined in from the users table
+// File and Line anchor the comment to a line of the snippet, File being
+// the 1-based position of the file, and are both 0 when it isn't anchored
+// Deleted comments that still have replies are kept, without their content,
+// so the thread stays in one piece
+// Replies is only filled in by ForSnippet, oldest first
+type Comment struct {
+	ID        int
+	SnippetID int
+	ParentID  int
+	UserID    int
+	UserName  string
+	File      int
+	Line      int
+	Content   string
+	Deleted   bool
+	Created   time.Time
+	Replies   []*Comment
+}
+
+// Define CommentModelInterface for the methods a comment store provides
+// CommentModel is the MySQL implementation
+// Insert doesn't check the parent is on the same snippet, so callers have to
+type CommentModelInterface interface {
+	Insert(snippetID, userID, parentID, file, line int, content string) (int, error)
+	Get(id int) (*Comment, error)
+	ForSnippet(snippetID int) ([]*Comment, error)
+	Delete(id int) error
+}
+
+// Thread arranges a snippet's comments, oldest first, into a tree of the
+// top level comments with their replies
+// Deleted comments left with no replies are dropped
+func Thread(comments []*Comment) []*Comment {
+	byID := make(map[int]*Comment, len(comments))
+	for _, c := range comments {
+		c.Replies = nil
+		byID[c.ID] = c
+	}
+
+	roots := []*Comment{}
+
+	for _, c := range comments {
+		if parent, ok := byID[c.ParentID]; ok {
+			parent.Replies = append(parent.Replies, c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	return pruneDeleted(roots)
+}
+
+// Drop the deleted comments that have nothing but deleted comments under them
+func pruneDeleted(comments []*Comment) []*Comment {
+	kept := comments[:0]
+	for _, c := range comments {
+		c.Replies = pruneDeleted(c.Replies)
+		if !c.Deleted || len(c.Replies) > 0 {
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
+
+// Define CommentModel type which wraps db connection pool
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// This will add a comment to a snippet
+// Returns the ID of the new comment
+func (m *CommentModel) Insert(snippetID, userID, parentID, file, line int, content string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, parent_id, user_id, file, line, content, created)
+	VALUES(?, ?, ?, ?, ?, ?, UTC_TIMESTAMP())`
+
+	result, err := m.DB.Exec(stmt, snippetID, sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0}, userID, file, line, content)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// This will return a specific comment based on its ID
+func (m *CommentModel) Get(id int) (*Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.file, c.line, c.content, c.deleted, c.created
+	FROM comments c INNER JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c := &Comment{}
+
+	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.UserName, &c.File, &c.Line, &c.Content, &c.Deleted, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// This will return the comments on a snippet as threads, oldest first
+func (m *CommentModel) ForSnippet(snippetID int) ([]*Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.file, c.line, c.content, c.deleted, c.created
+	FROM comments c INNER JOIN users u ON u.id = c.user_id WHERE c.snippet_id = ? ORDER BY c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*Comment{}
+
+	for rows.Next() {
+		c := &Comment{}
+		err = rows.Scan(&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.UserName, &c.File, &c.Line, &c.Content, &c.Deleted, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return Thread(comments), nil
+}
+
+// This will delete a comment
+// A comment with replies only loses its content, so the replies keep their
+// place in the thread
+// Returns ErrNoRecord if there is no such comment, or it was already deleted
+func (m *CommentModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var replied bool
+
+	err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM comments WHERE parent_id = ?)`, id).Scan(&replied)
+	if err != nil {
+		return err
+	}
+
+	stmt := `DELETE FROM comments WHERE id = ? AND deleted = FALSE`
+	if replied {
+		stmt = `UPDATE comments SET content = '', deleted = TRUE WHERE id = ? AND deleted = FALSE`
+	}
+
+	result, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// A comment that was already deleted counts as missing
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return tx.Commit()
+}
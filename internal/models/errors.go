@@ -12,4 +12,7 @@ var (
 
 	// Error if duplicate email is used
 	ErrDuplicateEmail = errors.New("models: duplicate email")
+
+	// Error if a snippet is added to a collection it's already in
+	ErrDuplicateSnippet = errors.New("models: snippet already in collection")
 )
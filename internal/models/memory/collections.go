@@ -0,0 +1,229 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define CollectionModel type which wraps the in-memory DB
+type CollectionModel struct {
+	DB *DB
+}
+
+// This will create a new collection owned by userID
+// Returns the slug of the new collection
+func (m *CollectionModel) Insert(userID int, name, description, visibility string) (string, error) {
+	slug, err := models.GenerateSlug()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c := &models.Collection{
+		ID:          m.DB.nextID(),
+		Slug:        slug,
+		UserID:      userID,
+		Name:        name,
+		Description: description,
+		Visibility:  visibility,
+		Created:     time.Now().UTC().Truncate(time.Second),
+	}
+	m.DB.collections[c.ID] = c
+
+	return slug, nil
+}
+
+// Return a copy of a collection with UserName and Snippets filled in like
+// the SQL queries do
+// The caller must hold db.mu
+func (db *DB) collectionCopy(c *models.Collection) *models.Collection {
+	collection := *c
+	if u, ok := db.users[c.UserID]; ok {
+		collection.UserName = u.Name
+	}
+
+	for _, id := range db.collectionSnippets[c.ID] {
+		if s, ok := db.snippets[id]; ok && !s.Expired() {
+			collection.Snippets++
+		}
+	}
+
+	return &collection
+}
+
+// Take a snippet out of a collection, reporting whether it was in it
+// The caller must hold db.mu
+func (db *DB) removeFromCollection(collectionID, snippetID int) bool {
+	ids := db.collectionSnippets[collectionID]
+	for i, id := range ids {
+		if id == snippetID {
+			db.collectionSnippets[collectionID] = append(ids[:i:i], ids[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// This will return a copy of the collection with the given slug
+func (m *CollectionModel) Get(slug string) (*models.Collection, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, c := range m.DB.collections {
+		if c.Slug == slug {
+			return m.DB.collectionCopy(c), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// This will return all the collections belonging to a user, sorted by name
+func (m *CollectionModel) ByUser(userID int) ([]*models.Collection, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	collections := []*models.Collection{}
+
+	for _, c := range m.DB.collections {
+		if c.UserID == userID {
+			collections = append(collections, m.DB.collectionCopy(c))
+		}
+	}
+
+	sort.Slice(collections, func(i, j int) bool {
+		if collections[i].Name != collections[j].Name {
+			return collections[i].Name < collections[j].Name
+		}
+		return collections[i].ID < collections[j].ID
+	})
+
+	return collections, nil
+}
+
+// This will update the name, description and visibility of a collection
+// owned by userID
+// Returns models.ErrNoRecord if there is no such collection for this user
+func (m *CollectionModel) Update(id, userID int, name, description, visibility string) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.collections[id]
+	if !ok || c.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	c.Name = name
+	c.Description = description
+	c.Visibility = visibility
+
+	return nil
+}
+
+// This will delete a collection owned by userID
+// Returns models.ErrNoRecord if there is no such collection for this user
+func (m *CollectionModel) Delete(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.collections[id]
+	if !ok || c.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.DB.collections, id)
+	delete(m.DB.collectionSnippets, id)
+
+	return nil
+}
+
+// This will return copies of the unexpired snippets in a collection, in
+// order, with UserName filled in like the SQL queries
+func (m *CollectionModel) Snippets(collectionID int) ([]*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+
+	for _, id := range m.DB.collectionSnippets[collectionID] {
+		s, ok := m.DB.snippets[id]
+		if !ok || s.Expired() {
+			continue
+		}
+
+		snippet := m.DB.listedCopy(s)
+		if u, ok := m.DB.users[s.UserID]; ok {
+			snippet.UserName = u.Name
+		}
+		snippets = append(snippets, snippet)
+	}
+
+	return snippets, nil
+}
+
+// This will add a snippet to the end of a collection
+// Returns models.ErrDuplicateSnippet if it's already in the collection
+func (m *CollectionModel) AddSnippet(collectionID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, id := range m.DB.collectionSnippets[collectionID] {
+		if id == snippetID {
+			return models.ErrDuplicateSnippet
+		}
+	}
+
+	m.DB.collectionSnippets[collectionID] = append(m.DB.collectionSnippets[collectionID], snippetID)
+
+	return nil
+}
+
+// This will take a snippet out of a collection
+// Returns models.ErrNoRecord if it isn't in the collection
+func (m *CollectionModel) RemoveSnippet(collectionID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	if !m.DB.removeFromCollection(collectionID, snippetID) {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will move a snippet one place up or down a collection, swapping it
+// with the unexpired snippet next to it
+// Moving the first snippet up or the last one down does nothing
+// Returns models.ErrNoRecord if it isn't in the collection
+func (m *CollectionModel) MoveSnippet(collectionID, snippetID int, up bool) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	ids := m.DB.collectionSnippets[collectionID]
+
+	for i, id := range ids {
+		if id != snippetID {
+			continue
+		}
+
+		step := 1
+		if up {
+			step = -1
+		}
+
+		for j := i + step; j >= 0 && j < len(ids); j += step {
+			if s, ok := m.DB.snippets[ids[j]]; ok && !s.Expired() {
+				ids[i], ids[j] = ids[j], ids[i]
+				break
+			}
+		}
+
+		return nil
+	}
+
+	return models.ErrNoRecord
+}
@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define CommentModel type which wraps the in-memory DB
+type CommentModel struct {
+	DB *DB
+}
+
+// This will add a comment to a snippet
+// Returns the ID of the new comment
+func (m *CommentModel) Insert(snippetID, userID, parentID, file, line int, content string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c := &models.Comment{
+		ID:        m.DB.nextID(),
+		SnippetID: snippetID,
+		ParentID:  parentID,
+		UserID:    userID,
+		File:      file,
+		Line:      line,
+		Content:   content,
+		Created:   time.Now().UTC().Truncate(time.Second),
+	}
+	m.DB.comments[c.ID] = c
+
+	return c.ID, nil
+}
+
+// Return a copy of a comment with UserName filled in like the SQL queries do
+// The caller must hold db.mu
+func (db *DB) commentCopy(c *models.Comment) *models.Comment {
+	comment := *c
+	if u, ok := db.users[c.UserID]; ok {
+		comment.UserName = u.Name
+	}
+	return &comment
+}
+
+// This will return a copy of the comment with the given ID
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.comments[id]
+	if !ok {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.commentCopy(c), nil
+}
+
+// This will return copies of the comments on a snippet as threads, oldest first
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	comments := []*models.Comment{}
+
+	for _, c := range m.DB.comments {
+		if c.SnippetID == snippetID {
+			comments = append(comments, m.DB.commentCopy(c))
+		}
+	}
+
+	sort.Slice(comments, func(i, j int) bool {
+		return comments[i].ID < comments[j].ID
+	})
+
+	return models.Thread(comments), nil
+}
+
+// This will delete a comment
+// A comment with replies only loses its content, so the replies keep their
+// place in the thread
+// Returns models.ErrNoRecord if there is no such comment, or it was already
+// deleted
+func (m *CommentModel) Delete(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	c, ok := m.DB.comments[id]
+	if !ok || c.Deleted {
+		return models.ErrNoRecord
+	}
+
+	for _, reply := range m.DB.comments {
+		if reply.ParentID == id {
+			c.Content = ""
+			c.Deleted = true
+			return nil
+		}
+	}
+
+	delete(m.DB.comments, id)
+
+	return nil
+}
@@ -0,0 +1,63 @@
+// Package memory implements the models interfaces with in-memory maps
+// It's used by the handler tests and needs no database at all
+package memory
+
+import (
+	"sync"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define DB type to hold the in-memory tables
+// It plays the part of sql.DB for the models in this package, and the
+// mutex guards every table
+// Revisions are kept per snippet ID, oldest first, and the snippet IDs in
+// each collection in order
+// Stars map to a sequence number from nextID, so they can be listed in the
+// order they were made
+// Comments are stored flat and threaded when they're read
+type DB struct {
+	mu                 sync.Mutex
+	users              map[int]*models.User
+	snippets           map[int]*models.Snippet
+	revisions          map[int][]*models.SnippetRevision
+	tokens             map[int]*token
+	collections        map[int]*models.Collection
+	collectionSnippets map[int][]int
+	stars              map[star]int
+	comments           map[int]*models.Comment
+	lastID             int
+}
+
+// A stored token keeps its hash alongside the public fields
+type token struct {
+	models.Token
+	hash string
+}
+
+// New returns an empty in-memory database
+func New() *DB {
+	return &DB{
+		users:              map[int]*models.User{},
+		snippets:           map[int]*models.Snippet{},
+		revisions:          map[int][]*models.SnippetRevision{},
+		tokens:             map[int]*token{},
+		collections:        map[int]*models.Collection{},
+		collectionSnippets: map[int][]int{},
+		stars:              map[star]int{},
+		comments:           map[int]*models.Comment{},
+	}
+}
+
+// A star is keyed by user and snippet, like the primary key of the table
+type star struct {
+	userID    int
+	snippetID int
+}
+
+// Return the next ID, shared by all tables like an auto-increment column
+// The caller must hold db.mu
+func (db *DB) nextID() int {
+	db.lastID++
+	return db.lastID
+}
@@ -0,0 +1,64 @@
+package memory
+
+import (
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Save the snippet's current title and files as its next revision
+// The caller must hold db.mu
+func (db *DB) addRevision(s *models.Snippet) {
+	revisions := db.revisions[s.ID]
+
+	db.revisions[s.ID] = append(revisions, &models.SnippetRevision{
+		ID:        db.nextID(),
+		SnippetID: s.ID,
+		Revision:  len(revisions) + 1,
+		Title:     s.Title,
+		Files:     copyFiles(s.Files),
+		Created:   s.Updated,
+		UserID:    s.UserID,
+	})
+}
+
+// Return a copy of a revision with UserName filled in like the SQL join does
+// The caller must hold db.mu
+func (db *DB) revisionCopy(r *models.SnippetRevision) *models.SnippetRevision {
+	revision := *r
+	revision.Files = copyFiles(r.Files)
+	if u, ok := db.users[r.UserID]; ok {
+		revision.UserName = u.Name
+	}
+	return &revision
+}
+
+// This will return every revision of a snippet, newest first
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.SnippetRevision, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	stored := m.DB.revisions[snippetID]
+	revisions := make([]*models.SnippetRevision, 0, len(stored))
+
+	for i := len(stored) - 1; i >= 0; i-- {
+		// Only Revision() fills in the files, like the SQL implementations
+		revision := m.DB.revisionCopy(stored[i])
+		revision.Files = nil
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+// This will return one revision of a snippet
+// Returns models.ErrNoRecord if the snippet has no such revision
+func (m *SnippetModel) Revision(snippetID, revision int) (*models.SnippetRevision, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	stored := m.DB.revisions[snippetID]
+	if revision < 1 || revision > len(stored) {
+		return nil, models.ErrNoRecord
+	}
+
+	return m.DB.revisionCopy(stored[revision-1]), nil
+}
@@ -0,0 +1,355 @@
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define SnippetModel type which wraps the in-memory DB
+type SnippetModel struct {
+	DB *DB
+}
+
+// This will insert a new snippet
+// Returns the slug of the new snippet
+func (m *SnippetModel) Insert(snippet models.NewSnippet) (string, error) {
+	slug, err := models.GenerateSlug()
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := models.HashSnippetPassword(snippet.Password)
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	now := time.Now().UTC().Truncate(time.Second)
+
+	s := &models.Snippet{
+		ID:               m.DB.nextID(),
+		Slug:             slug,
+		Title:            snippet.Title,
+		Files:            copyFiles(snippet.Files),
+		Tags:             sortedTags(snippet.Tags),
+		Visibility:       snippet.Visibility,
+		BurnAfterReading: snippet.BurnAfterReading,
+		HashedPassword:   hashedPassword,
+		ForkedFrom:       snippet.ForkedFrom,
+		Created:          now,
+		Updated:          now,
+		UserID:           snippet.UserID,
+	}
+	// Copy the expiry, rounded like a DATETIME column
+	if snippet.Expires != nil {
+		e := snippet.Expires.UTC().Truncate(time.Second)
+		s.Expires = &e
+	}
+	m.DB.snippets[s.ID] = s
+	m.DB.addRevision(s)
+
+	return s.Slug, nil
+}
+
+// Return copies of files, so callers can't change what's stored
+func copyFiles(files []*models.SnippetFile) []*models.SnippetFile {
+	copies := make([]*models.SnippetFile, 0, len(files))
+	for _, f := range files {
+		file := *f
+		copies = append(copies, &file)
+	}
+	return copies
+}
+
+// Return a sorted copy of tags, like the SQL queries return them
+func sortedTags(tags []string) []string {
+	sorted := append([]string{}, tags...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+// Return a copy of a snippet for listings, which leave out UserName and
+// Forks like the SQL queries
+// The caller must hold db.mu
+func (db *DB) listedCopy(s *models.Snippet) *models.Snippet {
+	snippet := *s
+	snippet.Files = copyFiles(s.Files)
+	snippet.Tags = sortedTags(s.Tags)
+	snippet.Stars = db.starCount(s.ID)
+	return &snippet
+}
+
+// Return how many users have starred a snippet
+// The caller must hold db.mu
+func (db *DB) starCount(snippetID int) int {
+	count := 0
+	for st := range db.stars {
+		if st.snippetID == snippetID {
+			count++
+		}
+	}
+	return count
+}
+
+// Return a copy of a snippet with UserName and Forks filled in like the
+// SQL queries do
+// The caller must hold db.mu
+func (db *DB) snippetCopy(s *models.Snippet) *models.Snippet {
+	snippet := *db.listedCopy(s)
+	if u, ok := db.users[s.UserID]; ok {
+		snippet.UserName = u.Name
+	}
+
+	for _, fork := range db.snippets {
+		if fork.ForkedFrom == s.ID && !fork.Expired() {
+			snippet.Forks++
+		}
+	}
+
+	return &snippet
+}
+
+// Delete a snippet along with its revisions and stars, take it out of
+// collections, and unlink its forks like ON DELETE SET NULL does
+// The caller must hold db.mu
+func (db *DB) deleteSnippet(id int) {
+	delete(db.snippets, id)
+	delete(db.revisions, id)
+
+	for collectionID := range db.collectionSnippets {
+		db.removeFromCollection(collectionID, id)
+	}
+
+	for st := range db.stars {
+		if st.snippetID == id {
+			delete(db.stars, st)
+		}
+	}
+
+	for commentID, c := range db.comments {
+		if c.SnippetID == id {
+			delete(db.comments, commentID)
+		}
+	}
+
+	for _, fork := range db.snippets {
+		if fork.ForkedFrom == id {
+			fork.ForkedFrom = 0
+		}
+	}
+}
+
+// This will return a copy of a snippet that hasn't expired
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.get(func(s *models.Snippet) bool { return s.ID == id })
+}
+
+// This will return a copy of the unexpired snippet with the given slug
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get(func(s *models.Snippet) bool { return s.Slug == slug })
+}
+
+// Return a copy of the unexpired snippet that match accepts, for Get() and
+// GetBySlug()
+func (m *SnippetModel) get(match func(s *models.Snippet) bool) (*models.Snippet, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, s := range m.DB.snippets {
+		if match(s) && !s.Expired() {
+			return m.DB.snippetCopy(s), nil
+		}
+	}
+
+	return nil, models.ErrNoRecord
+}
+
+// This will return a page of public snippets, newest first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) Latest(limit, offset int) ([]*models.Snippet, int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+
+	for _, s := range m.DB.snippets {
+		if !s.Expired() && s.Listed() {
+			snippets = append(snippets, m.DB.listedCopy(s))
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	return paginate(snippets, limit, offset), len(snippets), nil
+}
+
+// This will return a page of public snippets with the given tag, newest first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) ByTag(tag string, limit, offset int) ([]*models.Snippet, int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+
+	for _, s := range m.DB.snippets {
+		if s.Expired() || !s.Listed() {
+			continue
+		}
+		for _, t := range s.Tags {
+			if t == tag {
+				snippets = append(snippets, m.DB.listedCopy(s))
+				break
+			}
+		}
+	}
+
+	sortNewestFirst(snippets)
+
+	return paginate(snippets, limit, offset), len(snippets), nil
+}
+
+// Sort like the SQL implementations, by created then id, newest first
+func sortNewestFirst(snippets []*models.Snippet) {
+	sort.Slice(snippets, func(i, j int) bool {
+		if !snippets[i].Created.Equal(snippets[j].Created) {
+			return snippets[i].Created.After(snippets[j].Created)
+		}
+		return snippets[i].ID > snippets[j].ID
+	})
+}
+
+// Return the slice for LIMIT limit OFFSET offset
+func paginate(snippets []*models.Snippet, limit, offset int) []*models.Snippet {
+	if offset >= len(snippets) {
+		return []*models.Snippet{}
+	}
+
+	end := offset + limit
+	if end > len(snippets) {
+		end = len(snippets)
+	}
+
+	return snippets[offset:end]
+}
+
+// This will update the title, files, tags and visibility of a snippet owned by snippet.UserID
+// Returns models.ErrNoRecord if there is no such unexpired snippet for this user
+func (m *SnippetModel) Update(snippet models.SnippetUpdate) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[snippet.ID]
+	if !ok || s.UserID != snippet.UserID || s.Expired() {
+		return models.ErrNoRecord
+	}
+
+	s.Title = snippet.Title
+	s.Files = copyFiles(snippet.Files)
+	s.Tags = sortedTags(snippet.Tags)
+	s.Visibility = snippet.Visibility
+	s.Updated = time.Now().UTC().Truncate(time.Second)
+	m.DB.addRevision(s)
+
+	return nil
+}
+
+// This will delete a burn after reading snippet as it's read
+// Returns models.ErrNoRecord if it was already deleted by another request
+func (m *SnippetModel) Burn(id int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || !s.BurnAfterReading {
+		return models.ErrNoRecord
+	}
+
+	m.DB.deleteSnippet(id)
+
+	return nil
+}
+
+// This will delete a snippet owned by userID
+// Returns models.ErrNoRecord if there is no such snippet for this user
+func (m *SnippetModel) Delete(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	s, ok := m.DB.snippets[id]
+	if !ok || s.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	m.DB.deleteSnippet(id)
+
+	return nil
+}
+
+// This will delete up to limit expired snippets
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	deleted := 0
+
+	for id, s := range m.DB.snippets {
+		if deleted == limit {
+			break
+		}
+		if s.Expired() {
+			m.DB.deleteSnippet(id)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
+
+// This will return a page of public snippets matching query, most relevant first
+// Scored like the SQLite implementation, 2 for each search term in the
+// title and 1 for each in any of the files
+func (m *SnippetModel) Search(query string, limit, offset int) ([]*models.Snippet, int, error) {
+	terms := models.SearchTerms(query)
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	scores := map[int]int{}
+
+	for _, s := range m.DB.snippets {
+		if s.Expired() || !s.Listed() {
+			continue
+		}
+
+		score := 0
+		for _, term := range terms {
+			if strings.Contains(strings.ToLower(s.Title), term) {
+				score += 2
+			}
+			for _, f := range s.Files {
+				if strings.Contains(strings.ToLower(f.Content), term) {
+					score++
+					break
+				}
+			}
+		}
+
+		if score > 0 {
+			snippets = append(snippets, m.DB.listedCopy(s))
+			scores[s.ID] = score
+		}
+	}
+
+	sortNewestFirst(snippets)
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return scores[snippets[i].ID] > scores[snippets[j].ID]
+	})
+
+	return paginate(snippets, limit, offset), len(snippets), nil
+}
@@ -0,0 +1,101 @@
+package memory
+
+import (
+	"sort"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define StarModel type which wraps the in-memory DB
+type StarModel struct {
+	DB *DB
+}
+
+// This will star a snippet for a user
+// Starring a snippet twice does nothing
+func (m *StarModel) Insert(userID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	st := star{userID: userID, snippetID: snippetID}
+	if _, ok := m.DB.stars[st]; !ok {
+		m.DB.stars[st] = m.DB.nextID()
+	}
+
+	return nil
+}
+
+// This will unstar a snippet for a user
+// Unstarring a snippet that isn't starred does nothing
+func (m *StarModel) Delete(userID, snippetID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	delete(m.DB.stars, star{userID: userID, snippetID: snippetID})
+
+	return nil
+}
+
+// Exists reports whether the user has starred the snippet
+func (m *StarModel) Exists(userID, snippetID int) (bool, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	_, ok := m.DB.stars[star{userID: userID, snippetID: snippetID}]
+	return ok, nil
+}
+
+// This will return a page of the snippets a user has starred, most recently
+// starred first
+// Snippets made private since are left out, unless they're the user's own
+// Also returns the total number of them for pagination
+func (m *StarModel) Starred(userID, limit, offset int) ([]*models.Snippet, int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+	starredAt := map[int]int{}
+
+	for st, seq := range m.DB.stars {
+		if st.userID != userID {
+			continue
+		}
+
+		s, ok := m.DB.snippets[st.snippetID]
+		if !ok || s.Expired() || !s.VisibleTo(userID) {
+			continue
+		}
+
+		snippets = append(snippets, m.DB.listedCopy(s))
+		starredAt[s.ID] = seq
+	}
+
+	sort.Slice(snippets, func(i, j int) bool {
+		return starredAt[snippets[i].ID] > starredAt[snippets[j].ID]
+	})
+
+	return paginate(snippets, limit, offset), len(snippets), nil
+}
+
+// This will return a page of public snippets, most starred first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) MostStarred(limit, offset int) ([]*models.Snippet, int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	snippets := []*models.Snippet{}
+
+	for _, s := range m.DB.snippets {
+		if !s.Expired() && s.Listed() {
+			snippets = append(snippets, m.DB.listedCopy(s))
+		}
+	}
+
+	// Newest first among snippets with the same number of stars
+	sortNewestFirst(snippets)
+	sort.SliceStable(snippets, func(i, j int) bool {
+		return snippets[i].Stars > snippets[j].Stars
+	})
+
+	return paginate(snippets, limit, offset), len(snippets), nil
+}
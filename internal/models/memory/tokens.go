@@ -0,0 +1,92 @@
+package memory
+
+import (
+	"sort"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define TokenModel type which wraps the in-memory DB
+type TokenModel struct {
+	DB *DB
+}
+
+// This will create a new named token for a user
+// Returns the plain-text token, which can't be recovered later
+func (m *TokenModel) Insert(userID int, name string) (string, error) {
+	plaintext, hash, err := models.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t := &token{
+		Token: models.Token{
+			ID:      m.DB.nextID(),
+			UserID:  userID,
+			Name:    name,
+			Created: time.Now().UTC().Truncate(time.Second),
+		},
+		hash: string(hash),
+	}
+	m.DB.tokens[t.ID] = t
+
+	return plaintext, nil
+}
+
+// This will return all the tokens belonging to a user, newest first
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	tokens := []*models.Token{}
+
+	for _, t := range m.DB.tokens {
+		if t.UserID == userID {
+			tok := t.Token
+			tokens = append(tokens, &tok)
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].ID > tokens[j].ID
+	})
+
+	return tokens, nil
+}
+
+// This will revoke a token belonging to userID
+// Returns models.ErrNoRecord if the user has no such token
+func (m *TokenModel) Delete(id, userID int) error {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	t, ok := m.DB.tokens[id]
+	if !ok || t.UserID != userID {
+		return models.ErrNoRecord
+	}
+
+	delete(m.DB.tokens, id)
+
+	return nil
+}
+
+// Authenticate returns the ID of the user the plain-text token belongs to
+// Returns models.ErrInvalidCredentials if it doesn't match any token
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	hash := string(models.HashToken(plaintext))
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, t := range m.DB.tokens {
+		if t.hash == hash {
+			return t.UserID, nil
+		}
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
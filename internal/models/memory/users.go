@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"errors"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Define UserModel type which wraps the in-memory DB
+type UserModel struct {
+	DB *DB
+}
+
+// The bcrypt cost is kept low since this store is only used in tests
+const bcryptCost = bcrypt.MinCost
+
+func (m *UserModel) Insert(name, email, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
+	if err != nil {
+		return err
+	}
+
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email == email {
+			return models.ErrDuplicateEmail
+		}
+	}
+
+	u := &models.User{
+		ID:             m.DB.nextID(),
+		Name:           name,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Created:        time.Now().UTC().Truncate(time.Second),
+	}
+	m.DB.users[u.ID] = u
+
+	return nil
+}
+
+// Authentication method returns user ID if valid email and password
+func (m *UserModel) Authenticate(email, password string) (int, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	for _, u := range m.DB.users {
+		if u.Email != email {
+			continue
+		}
+
+		err := bcrypt.CompareHashAndPassword(u.HashedPassword, []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return 0, models.ErrInvalidCredentials
+			} else {
+				return 0, err
+			}
+		}
+
+		return u.ID, nil
+	}
+
+	return 0, models.ErrInvalidCredentials
+}
+
+// Check if the users exists with the specific ID
+func (m *UserModel) Exists(id int) (bool, error) {
+	m.DB.mu.Lock()
+	defer m.DB.mu.Unlock()
+
+	_, ok := m.DB.users[id]
+	return ok, nil
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"strings"
+)
+
+// Most words of a search query that are used
+const maxSearchTerms = 10
+
+// SearchTerms splits a search query into distinct lower-case words
+// Used by the stores without a full-text index and for highlighting
+func SearchTerms(query string) []string {
+	terms := []string{}
+	seen := map[string]bool{}
+
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+
+		terms = append(terms, word)
+		if len(terms) == maxSearchTerms {
+			break
+		}
+	}
+
+	return terms
+}
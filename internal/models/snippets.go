@@ -1,19 +1,158 @@
 package models
 
 import (
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"errors"
+	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Define Snippet type to hold data for an individual snippet
 // The fields of the struct correspond with the MySQL table
+// Slug is the random identifier used in URLs, so the sequential ID can't
+// be used to enumerate snippets
+// UserID is the ID of the user who created the snippet and UserName is
+// their display name, joined in from the users table
+// Files are the snippet's files in order, and there is always at least one
+// Tags are the snippet's tags sorted by name
+// Updated is when the snippet was last edited, and equals Created until then
+// Visibility is one of the Visibility constants
+// BurnAfterReading snippets are deleted the first time someone other than
+// their owner views them
+// HashedPassword is the bcrypt hash of the snippet's passphrase, or nil if
+// it doesn't have one
+// Expires is nil for snippets that never expire
+// ForkedFrom is the ID of the snippet this one was copied from, or 0, and
+// Forks is how many unexpired copies of this one there are. Only Get and
+// GetBySlug fill them in
+// Stars is how many users have starred the snippet
 type Snippet struct {
-	ID      int
-	Title   string
-	Content string
-	Created time.Time
-	Expires time.Time
+	ID               int
+	Slug             string
+	Title            string
+	Files            []*SnippetFile
+	Tags             []string
+	Visibility       string
+	BurnAfterReading bool
+	HashedPassword   []byte
+	Created          time.Time
+	Updated          time.Time
+	Expires          *time.Time
+	UserID           int
+	UserName         string
+	ForkedFrom       int
+	Forks            int
+	Stars            int
+}
+
+// Who can see a snippet
+// Public snippets are listed everywhere, unlisted ones can be viewed by
+// anyone with the link but are left out of listings and search results,
+// and private ones can only be viewed by their owner
+const (
+	VisibilityPublic   = "public"
+	VisibilityUnlisted = "unlisted"
+	VisibilityPrivate  = "private"
+)
+
+// Visibilities lists the valid visibility values, for validation
+var Visibilities = []string{VisibilityPublic, VisibilityUnlisted, VisibilityPrivate}
+
+// VisibleTo reports whether the user with the given ID can view the snippet
+// A userID of 0 means nobody is logged in
+func (s *Snippet) VisibleTo(userID int) bool {
+	return s.Visibility != VisibilityPrivate || (userID != 0 && s.UserID == userID)
+}
+
+// Listed reports whether the snippet belongs in listings and search results
+// Burn after reading snippets are left out, or the first person to browse
+// past one would destroy it, and so are password-protected ones, whose
+// content search results would give away
+func (s *Snippet) Listed() bool {
+	return s.Visibility == VisibilityPublic && !s.BurnAfterReading && !s.HasPassword()
+}
+
+// Expired reports whether the snippet's expiry has passed
+func (s *Snippet) Expired() bool {
+	return s.Expires != nil && !s.Expires.After(time.Now())
+}
+
+// HasPassword reports whether the snippet is protected by a passphrase
+func (s *Snippet) HasPassword() bool {
+	return s.HashedPassword != nil
+}
+
+// CheckPassword returns ErrInvalidCredentials if password isn't the
+// snippet's passphrase
+func (s *Snippet) CheckPassword(password string) error {
+	err := bcrypt.CompareHashAndPassword(s.HashedPassword, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return ErrInvalidCredentials
+		} else {
+			return err
+		}
+	}
+	return nil
+}
+
+// HashSnippetPassword returns the bcrypt hash of a snippet passphrase, with
+// the same cost as user passwords
+// An empty passphrase means none, and gives a nil hash
+func HashSnippetPassword(password string) ([]byte, error) {
+	if password == "" {
+		return nil, nil
+	}
+	return bcrypt.GenerateFromPassword([]byte(password), 12)
+}
+
+// Define NewSnippet type for the details of a snippet being created
+// Password is optional, and is stored as a bcrypt hash
+// A nil Expires means the snippet never expires, and ForkedFrom is the ID
+// of the snippet it's a copy of, or 0
+type NewSnippet struct {
+	UserID           int
+	Title            string
+	Files            []*SnippetFile
+	Tags             []string
+	Visibility       string
+	Password         string
+	BurnAfterReading bool
+	Expires          *time.Time
+	ForkedFrom       int
+}
+
+// Define SnippetUpdate type for the new details of an edited snippet
+// UserID must be the owner's ID, or the update is refused
+type SnippetUpdate struct {
+	ID         int
+	UserID     int
+	Title      string
+	Files      []*SnippetFile
+	Tags       []string
+	Visibility string
+}
+
+// Define SnippetModelInterface for the methods a snippet store provides
+// SnippetModel is the MySQL implementation
+type SnippetModelInterface interface {
+	Insert(snippet NewSnippet) (string, error)
+	Get(id int) (*Snippet, error)
+	GetBySlug(slug string) (*Snippet, error)
+	Burn(id int) error
+	Latest(limit, offset int) ([]*Snippet, int, error)
+	MostStarred(limit, offset int) ([]*Snippet, int, error)
+	Search(query string, limit, offset int) ([]*Snippet, int, error)
+	ByTag(tag string, limit, offset int) ([]*Snippet, int, error)
+	Update(snippet SnippetUpdate) error
+	Revisions(snippetID int) ([]*SnippetRevision, error)
+	Revision(snippetID, revision int) (*SnippetRevision, error)
+	Delete(id, userID int) error
+	DeleteExpired(limit int) (int, error)
 }
 
 // Define SnippetModel type which wraps sql.DB
@@ -21,44 +160,122 @@ type SnippetModel struct {
 	DB *sql.DB
 }
 
+// The WHERE condition for snippets that appear in listings, the unexpired
+// ones that Snippet.Listed() accepts
+// Queries using it alias the snippets table as s
+const listedSnippets = `(s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.visibility = 'public' AND NOT s.burn_after_reading AND s.hashed_password IS NULL`
+
+// GenerateSlug returns a random URL-safe snippet identifier
+// 12 bytes from crypto/rand make collisions too unlikely to retry for, and
+// the unique index on snippets.slug would reject one anyway
+func GenerateSlug() (string, error) {
+	b := make([]byte, 12)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 // This will insert a new snippet into the database
-func (m *SnippetModel) Insert(title string, content string, expires int) (int, error) {
+// Returns the slug of the new snippet
+func (m *SnippetModel) Insert(snippet NewSnippet) (string, error) {
+	slug, err := GenerateSlug()
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := HashSnippetPassword(snippet.Password)
+	if err != nil {
+		return "", err
+	}
+
 	// Write the SQL statement to be executed
-	stmt := `INSERT INTO snippets (title, content, created, expires) 
-	VALUES(?, ?, UTC_TIMESTAMP(), DATE_ADD(UTC_TIMESTAMP(), INTERVAL ? DAY))`
+	stmt := `INSERT INTO snippets (slug, user_id, title, visibility, burn_after_reading, hashed_password, forked_from, created, updated, expires) 
+	VALUES(?, ?, ?, ?, ?, ?, ?, UTC_TIMESTAMP(), UTC_TIMESTAMP(), ?)`
 
 	// Use Exec() method to execute the statement
-	result, err := m.DB.Exec(stmt, title, content, expires)
+	// The snippet, its files and tags and its first revision are saved together
+	tx, err := m.DB.Begin()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, slug, snippet.UserID, snippet.Title, snippet.Visibility, snippet.BurnAfterReading, hashedPassword, sql.NullInt64{Int64: int64(snippet.ForkedFrom), Valid: snippet.ForkedFrom != 0}, expiresValue(snippet.Expires))
+	if err != nil {
+		return "", err
 	}
 
-	// Use LastInsertId() to get ID of our newly inserted record in the snippets table
 	id, err := result.LastInsertId()
 	if err != nil {
-		return 0, err
+		return "", err
+	}
+
+	err = insertFiles(tx, int(id), snippet.Files)
+	if err != nil {
+		return "", err
+	}
+
+	err = setTags(tx, int(id), snippet.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = saveRevision(tx, int(id), 1)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
 	}
 
-	// The returned ID is int64
-	// Convert to int
-	return int(id), nil
+	return slug, nil
+}
+
+// Convert an optional expiry to a UTC value for the expires column
+func expiresValue(expires *time.Time) any {
+	if expires == nil {
+		return nil
+	}
+	return expires.UTC()
 }
 
 // This will return a specific snippet based on ID
 func (m *SnippetModel) Get(id int) (*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets 
-	WHERE expires > UTC_TIMESTAMP() AND id = ?`
+	return m.get("id", id)
+}
+
+// This will return a specific snippet based on its slug
+func (m *SnippetModel) GetBySlug(slug string) (*Snippet, error) {
+	return m.get("slug", slug)
+}
+
+// Return the unexpired snippet whose column equals value, with its files
+// and tags, for Get() and GetBySlug()
+// Join the users table to pick up the author's name
+// column is always a constant, never user input
+func (m *SnippetModel) get(column string, value any) (*Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.updated, s.expires, s.user_id, u.name, COALESCE(s.forked_from, 0), 
+	(SELECT COUNT(*) FROM snippets f WHERE f.forked_from = s.id AND (f.expires IS NULL OR f.expires > UTC_TIMESTAMP())) AS forks, 
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id) AS stars 
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id 
+	WHERE (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND s.` + column + ` = ?`
 
 	// Use QueryRow() to execute SQL statement
-	// Uses the id variable as the ? placeholder param
+	// Uses the value variable as the ? placeholder param
 	// Returns a pointer to sql.Row
-	row := m.DB.QueryRow(stmt, id)
+	row := m.DB.QueryRow(stmt, value)
 
 	// Init a pointer to a new zeroed Snippet struct
 	s := &Snippet{}
 
 	// Use row.Scan() to copy values from sql.Row to Snippet struct
-	err := row.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+	err := row.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.UserName, &s.ForkedFrom, &s.Forks, &s.Stars)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrNoRecord
@@ -66,17 +283,35 @@ func (m *SnippetModel) Get(id int) (*Snippet, error) {
 			return nil, err
 		}
 	}
+
+	s.Files, err = m.files(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Tags, err = m.tags(s.ID)
+	if err != nil {
+		return nil, err
+	}
 	return s, nil
 }
 
-// This will return the 10 most recent snippets
-func (m *SnippetModel) Latest() ([]*Snippet, error) {
-	stmt := `SELECT id, title, content, created, expires FROM snippets 
-	WHERE expires > UTC_TIMESTAMP() ORDER BY id LIMIT 10`
+// This will return a page of public snippets, newest first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) Latest(limit, offset int) ([]*Snippet, int, error) {
+	var total int
 
-	rows, err := m.DB.Query(stmt)
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets s WHERE ` + listedSnippets).Scan(&total)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id FROM snippets s 
+	WHERE ` + listedSnippets + ` ORDER BY created DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Defer rows.Close() to ensure result set is properly closed before
@@ -92,9 +327,9 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 		s := &Snippet{}
 		// Use rows.Scan() to copy values from each field in the row
 		// To the new Snippet object
-		err = rows.Scan(&s.ID, &s.Title, &s.Content, &s.Created, &s.Expires)
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID)
 		if err != nil {
-			return nil, err
+			return nil, 0, err
 		}
 		// Append to the slice
 		snippets = append(snippets, s)
@@ -102,8 +337,216 @@ func (m *SnippetModel) Latest() ([]*Snippet, error) {
 
 	// Call rows.Err() to retrieve any errors during the iteration
 	if err = rows.Err(); err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
 	}
 	// Otherwise, everything went OK
-	return snippets, nil
+	return snippets, total, nil
+}
+
+// This will update the title, files, tags and visibility of a snippet
+// The user_id condition means only the owner can change it
+// The new title and files are also saved as the next revision
+// Returns ErrNoRecord if there is no such unexpired snippet for this user
+func (m *SnippetModel) Update(snippet SnippetUpdate) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// RowsAffected() doesn't count rows the update left unchanged, so
+	// check the snippet is there first
+	var count int
+
+	err = tx.QueryRow(`SELECT COUNT(*) FROM snippets WHERE id = ? AND user_id = ? AND (expires IS NULL OR expires > UTC_TIMESTAMP()) FOR UPDATE`, snippet.ID, snippet.UserID).Scan(&count)
+	if err != nil {
+		return err
+	}
+
+	if count == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = tx.Exec(`UPDATE snippets SET title = ?, visibility = ?, updated = UTC_TIMESTAMP() WHERE id = ?`, snippet.Title, snippet.Visibility, snippet.ID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = ?`, snippet.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertFiles(tx, snippet.ID, snippet.Files)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, snippet.ID, snippet.Tags)
+	if err != nil {
+		return err
+	}
+
+	var revision int
+
+	err = tx.QueryRow(`SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`, snippet.ID).Scan(&revision)
+	if err != nil {
+		return err
+	}
+
+	err = saveRevision(tx, snippet.ID, revision)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Return the files of a snippet in order
+func (m *SnippetModel) files(snippetID int) ([]*SnippetFile, error) {
+	return files(m.DB, `SELECT filename, language, content FROM snippet_files WHERE snippet_id = ? ORDER BY position`, snippetID)
+}
+
+// This will delete a burn after reading snippet as it's read
+// Returns ErrNoRecord if it was already deleted, so when two requests read
+// the snippet at the same time, only the one whose delete succeeded may
+// show the content
+func (m *SnippetModel) Burn(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND burn_after_reading`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will delete a snippet owned by userID
+// Returns ErrNoRecord if there is no such snippet for this user
+func (m *SnippetModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will delete up to limit expired snippets
+// Returns how many were deleted, so callers can work in batches
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE expires <= UTC_TIMESTAMP() LIMIT ?`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// This will return a page of public snippets matching query, most relevant first
+// Uses the FULLTEXT indexes on titles and file contents in natural language
+// mode, with a title match counting double
+func (m *SnippetModel) Search(query string, limit, offset int) ([]*Snippet, int, error) {
+	terms := SearchTerms(query)
+	if len(terms) == 0 {
+		return []*Snippet{}, 0, nil
+	}
+	query = strings.Join(terms, " ")
+
+	scored := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id, 
+	MATCH(title) AGAINST(? IN NATURAL LANGUAGE MODE) * 2 + 
+	(SELECT COALESCE(SUM(MATCH(f.content) AGAINST(? IN NATURAL LANGUAGE MODE)), 0) FROM snippet_files f WHERE f.snippet_id = s.id) AS score 
+	FROM snippets s 
+	WHERE ` + listedSnippets
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM (`+scored+`) AS scored WHERE score > 0`, query, query).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id, score FROM (` + scored + `) AS scored 
+	WHERE score > 0 ORDER BY score DESC, created DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, query, query, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		var score float64
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID, &score)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
 }
@@ -0,0 +1,120 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define CommentModel type which wraps a SQLite sql.DB
+type CommentModel struct {
+	DB *sql.DB
+}
+
+// This will add a comment to a snippet
+// Returns the ID of the new comment
+func (m *CommentModel) Insert(snippetID, userID, parentID, file, line int, content string) (int, error) {
+	stmt := `INSERT INTO comments (snippet_id, parent_id, user_id, file, line, content, created)
+	VALUES(?, ?, ?, ?, ?, ?, datetime('now'))`
+
+	result, err := m.DB.Exec(stmt, snippetID, sql.NullInt64{Int64: int64(parentID), Valid: parentID != 0}, userID, file, line, content)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// This will return a specific comment based on its ID
+func (m *CommentModel) Get(id int) (*models.Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.file, c.line, c.content, c.deleted, c.created
+	FROM comments c INNER JOIN users u ON u.id = c.user_id WHERE c.id = ?`
+
+	c := &models.Comment{}
+
+	err := m.DB.QueryRow(stmt, id).Scan(&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.UserName, &c.File, &c.Line, &c.Content, &c.Deleted, &c.Created)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// This will return the comments on a snippet as threads, oldest first
+func (m *CommentModel) ForSnippet(snippetID int) ([]*models.Comment, error) {
+	stmt := `SELECT c.id, c.snippet_id, COALESCE(c.parent_id, 0), c.user_id, u.name, c.file, c.line, c.content, c.deleted, c.created
+	FROM comments c INNER JOIN users u ON u.id = c.user_id WHERE c.snippet_id = ? ORDER BY c.id`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []*models.Comment{}
+
+	for rows.Next() {
+		c := &models.Comment{}
+		err = rows.Scan(&c.ID, &c.SnippetID, &c.ParentID, &c.UserID, &c.UserName, &c.File, &c.Line, &c.Content, &c.Deleted, &c.Created)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return models.Thread(comments), nil
+}
+
+// This will delete a comment
+// A comment with replies only loses its content, so the replies keep their
+// place in the thread
+// Returns ErrNoRecord if there is no such comment, or it was already deleted
+func (m *CommentModel) Delete(id int) error {
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var replied bool
+
+	err = tx.QueryRow(`SELECT EXISTS(SELECT true FROM comments WHERE parent_id = ?)`, id).Scan(&replied)
+	if err != nil {
+		return err
+	}
+
+	stmt := `DELETE FROM comments WHERE id = ? AND deleted = FALSE`
+	if replied {
+		stmt = `UPDATE comments SET content = '', deleted = TRUE WHERE id = ? AND deleted = FALSE`
+	}
+
+	result, err := tx.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	// A comment that was already deleted counts as missing
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,113 @@
+package sqlite
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define querier for the part of sql.DB and sql.Tx that loading files needs
+type querier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Insert the files of a snippet, numbering their positions from 1
+func insertFiles(tx *sql.Tx, snippetID int, files []*models.SnippetFile) error {
+	stmt := `INSERT INTO snippet_files (snippet_id, position, filename, language, content) VALUES(?, ?, ?, ?, ?)`
+
+	for i, f := range files {
+		_, err := tx.Exec(stmt, snippetID, i+1, f.Filename, f.Language, f.Content)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Save the snippet's current title and files as the given revision
+func saveRevision(tx *sql.Tx, snippetID, revision int) error {
+	stmt := `INSERT INTO snippet_revisions (snippet_id, revision, user_id, title, created)
+	SELECT id, ?, user_id, title, updated FROM snippets WHERE id = ?`
+
+	result, err := tx.Exec(stmt, revision, snippetID)
+	if err != nil {
+		return err
+	}
+
+	revisionID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	stmt = `INSERT INTO snippet_revision_files (revision_id, position, filename, language, content)
+	SELECT ?, position, filename, language, content FROM snippet_files WHERE snippet_id = ?`
+
+	_, err = tx.Exec(stmt, revisionID, snippetID)
+	return err
+}
+
+// Return the files from a query selecting filename, language and content
+func files(q querier, stmt string, args ...any) ([]*models.SnippetFile, error) {
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	files := []*models.SnippetFile{}
+
+	for rows.Next() {
+		f := &models.SnippetFile{}
+		err = rows.Scan(&f.Filename, &f.Language, &f.Content)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// Fill in the files of each of the snippets with a single query
+func loadFiles(q querier, snippets []*models.Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*models.Snippet, len(snippets))
+	placeholders := make([]string, 0, len(snippets))
+	args := make([]any, 0, len(snippets))
+
+	for _, s := range snippets {
+		s.Files = []*models.SnippetFile{}
+		byID[s.ID] = s
+		placeholders = append(placeholders, "?")
+		args = append(args, s.ID)
+	}
+
+	stmt := `SELECT snippet_id, filename, language, content FROM snippet_files
+	WHERE snippet_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY snippet_id, position`
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID int
+		f := &models.SnippetFile{}
+		err = rows.Scan(&snippetID, &f.Filename, &f.Language, &f.Content)
+		if err != nil {
+			return err
+		}
+		byID[snippetID].Files = append(byID[snippetID].Files, f)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,62 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// This will return every revision of a snippet, newest first
+func (m *SnippetModel) Revisions(snippetID int) ([]*models.SnippetRevision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.revision, r.title, r.created, r.user_id, u.name
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? ORDER BY r.revision DESC`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []*models.SnippetRevision{}
+
+	for rows.Next() {
+		r := &models.SnippetRevision{}
+		err = rows.Scan(&r.ID, &r.SnippetID, &r.Revision, &r.Title, &r.Created, &r.UserID, &r.UserName)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// This will return one revision of a snippet
+// Returns models.ErrNoRecord if the snippet has no such revision
+func (m *SnippetModel) Revision(snippetID, revision int) (*models.SnippetRevision, error) {
+	stmt := `SELECT r.id, r.snippet_id, r.revision, r.title, r.created, r.user_id, u.name
+	FROM snippet_revisions r INNER JOIN users u ON u.id = r.user_id
+	WHERE r.snippet_id = ? AND r.revision = ?`
+
+	r := &models.SnippetRevision{}
+
+	err := m.DB.QueryRow(stmt, snippetID, revision).Scan(&r.ID, &r.SnippetID, &r.Revision, &r.Title, &r.Created, &r.UserID, &r.UserName)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	r.Files, err = files(m.DB, `SELECT filename, language, content FROM snippet_revision_files WHERE revision_id = ? ORDER BY position`, r.ID)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
@@ -0,0 +1,380 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define SnippetModel type which wraps a SQLite sql.DB
+// Timestamps are written with datetime('now') so they compare as text
+type SnippetModel struct {
+	DB *sql.DB
+}
+
+// The WHERE condition for snippets that appear in listings, the unexpired
+// ones that models.Snippet.Listed() accepts
+// Queries using it alias the snippets table as s
+const listedSnippets = `(s.expires IS NULL OR s.expires > datetime('now')) AND s.visibility = 'public' AND NOT s.burn_after_reading AND s.hashed_password IS NULL`
+
+// This will insert a new snippet into the database
+// Returns the slug of the new snippet
+func (m *SnippetModel) Insert(snippet models.NewSnippet) (string, error) {
+	slug, err := models.GenerateSlug()
+	if err != nil {
+		return "", err
+	}
+
+	hashedPassword, err := models.HashSnippetPassword(snippet.Password)
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO snippets (slug, user_id, title, visibility, burn_after_reading, hashed_password, forked_from, created, updated, expires)
+	VALUES(?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'), ?)`
+
+	// The snippet, its files and tags and its first revision are saved together
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, slug, snippet.UserID, snippet.Title, snippet.Visibility, snippet.BurnAfterReading, hashedPassword, sql.NullInt64{Int64: int64(snippet.ForkedFrom), Valid: snippet.ForkedFrom != 0}, expiresValue(snippet.Expires))
+	if err != nil {
+		return "", err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return "", err
+	}
+
+	err = insertFiles(tx, int(id), snippet.Files)
+	if err != nil {
+		return "", err
+	}
+
+	err = setTags(tx, int(id), snippet.Tags)
+	if err != nil {
+		return "", err
+	}
+
+	err = saveRevision(tx, int(id), 1)
+	if err != nil {
+		return "", err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", err
+	}
+
+	return slug, nil
+}
+
+// Format an optional expiry the way datetime() does, so it compares as text
+func expiresValue(expires *time.Time) any {
+	if expires == nil {
+		return nil
+	}
+	return expires.UTC().Format("2006-01-02 15:04:05")
+}
+
+// This will return a specific snippet based on ID
+func (m *SnippetModel) Get(id int) (*models.Snippet, error) {
+	return m.get("id", id)
+}
+
+// This will return a specific snippet based on its slug
+func (m *SnippetModel) GetBySlug(slug string) (*models.Snippet, error) {
+	return m.get("slug", slug)
+}
+
+// Return the unexpired snippet whose column equals value, with its files
+// and tags, for Get() and GetBySlug()
+// column is always a constant, never user input
+func (m *SnippetModel) get(column string, value any) (*models.Snippet, error) {
+	stmt := `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.updated, s.expires, s.user_id, u.name, COALESCE(s.forked_from, 0),
+	(SELECT COUNT(*) FROM snippets f WHERE f.forked_from = s.id AND (f.expires IS NULL OR f.expires > datetime('now'))) AS forks,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id) AS stars
+	FROM snippets s INNER JOIN users u ON u.id = s.user_id
+	WHERE (s.expires IS NULL OR s.expires > datetime('now')) AND s.` + column + ` = ?`
+
+	s := &models.Snippet{}
+
+	err := m.DB.QueryRow(stmt, value).Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Updated, &s.Expires, &s.UserID, &s.UserName, &s.ForkedFrom, &s.Forks, &s.Stars)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, models.ErrNoRecord
+		} else {
+			return nil, err
+		}
+	}
+
+	s.Files, err = m.files(s.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Tags, err = m.tags(s.ID)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// This will return a page of public snippets, newest first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) Latest(limit, offset int) ([]*models.Snippet, int, error) {
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets s WHERE ` + listedSnippets).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id FROM snippets s
+	WHERE ` + listedSnippets + ` ORDER BY created DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
+
+// This will update the title, files, tags and visibility of a snippet owned by snippet.UserID
+// The new title and files are also saved as the next revision
+// Returns models.ErrNoRecord if there is no such unexpired snippet for this user
+func (m *SnippetModel) Update(snippet models.SnippetUpdate) error {
+	stmt := `UPDATE snippets SET title = ?, visibility = ?, updated = datetime('now')
+	WHERE id = ? AND user_id = ? AND (expires IS NULL OR expires > datetime('now'))`
+
+	tx, err := m.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(stmt, snippet.Title, snippet.Visibility, snippet.ID, snippet.UserID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	_, err = tx.Exec(`DELETE FROM snippet_files WHERE snippet_id = ?`, snippet.ID)
+	if err != nil {
+		return err
+	}
+
+	err = insertFiles(tx, snippet.ID, snippet.Files)
+	if err != nil {
+		return err
+	}
+
+	err = setTags(tx, snippet.ID, snippet.Tags)
+	if err != nil {
+		return err
+	}
+
+	var revision int
+
+	err = tx.QueryRow(`SELECT COALESCE(MAX(revision), 0) + 1 FROM snippet_revisions WHERE snippet_id = ?`, snippet.ID).Scan(&revision)
+	if err != nil {
+		return err
+	}
+
+	err = saveRevision(tx, snippet.ID, revision)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Return the files of a snippet in order
+func (m *SnippetModel) files(snippetID int) ([]*models.SnippetFile, error) {
+	return files(m.DB, `SELECT filename, language, content FROM snippet_files WHERE snippet_id = ? ORDER BY position`, snippetID)
+}
+
+// This will delete a burn after reading snippet as it's read
+// Returns models.ErrNoRecord if it was already deleted by another request
+func (m *SnippetModel) Burn(id int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND burn_after_reading`
+
+	result, err := m.DB.Exec(stmt, id)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will delete a snippet owned by userID
+// Returns models.ErrNoRecord if there is no such snippet for this user
+func (m *SnippetModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM snippets WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// This will delete up to limit expired snippets
+// SQLite doesn't support DELETE ... LIMIT by default, so use a subquery
+func (m *SnippetModel) DeleteExpired(limit int) (int, error) {
+	stmt := `DELETE FROM snippets WHERE id IN
+	(SELECT id FROM snippets WHERE expires <= datetime('now') LIMIT ?)`
+
+	result, err := m.DB.Exec(stmt, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(rows), nil
+}
+
+// This will return a page of public snippets matching query, most relevant first
+// SQLite has no FULLTEXT index here, so each search term scores 2 for a
+// match in the title and 1 for a match in any of the files using LIKE
+func (m *SnippetModel) Search(query string, limit, offset int) ([]*models.Snippet, int, error) {
+	terms := models.SearchTerms(query)
+	if len(terms) == 0 {
+		return []*models.Snippet{}, 0, nil
+	}
+
+	scores := make([]string, 0, len(terms))
+	args := make([]any, 0, len(terms)*2+2)
+
+	for _, term := range terms {
+		scores = append(scores, `(title LIKE ? ESCAPE '\') * 2 + EXISTS (SELECT 1 FROM snippet_files f WHERE f.snippet_id = s.id AND f.content LIKE ? ESCAPE '\')`)
+		pattern := "%" + escapeLike(term) + "%"
+		args = append(args, pattern, pattern)
+	}
+
+	scored := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id, ` + strings.Join(scores, " + ") + ` AS score
+	FROM snippets s WHERE ` + listedSnippets
+
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM (`+scored+`) WHERE score > 0`, args...).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT id, slug, title, visibility, burn_after_reading, hashed_password, created, expires, user_id FROM (` + scored + `)
+	WHERE score > 0 ORDER BY score DESC, created DESC, id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, append(args, limit, offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*models.Snippet{}
+
+	for rows.Next() {
+		s := &models.Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
+
+// Escape the LIKE wildcards in s, using backslash as the escape character
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
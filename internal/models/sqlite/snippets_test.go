@@ -0,0 +1,376 @@
+package sqlite
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+func TestSnippetModelGet(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "Alice", "alice@example.com")
+
+	m := &SnippetModel{DB: db}
+
+	expires := fromNow(7 * 24 * time.Hour)
+
+	slug, err := m.Insert(models.NewSnippet{UserID: userID, Title: "Hello", Files: oneFile("go", "package main"), Tags: []string{"go", "basics"}, Visibility: "public", Expires: expires})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Title != "Hello" || s.UserName != "Alice" || len(s.Files) != 1 || s.Files[0].Content != "package main" {
+		t.Errorf("want the new snippet; got %+v", s)
+	}
+
+	// datetime('now') is stored as UTC text, and must read back as the
+	// time the snippet was created
+	if d := time.Since(s.Created); d < -time.Minute || d > time.Minute {
+		t.Errorf("want created around now; got %v", s.Created)
+	}
+
+	if !s.Updated.Equal(s.Created) {
+		t.Errorf("want updated %v; got %v", s.Created, s.Updated)
+	}
+
+	if s.Expires == nil || !s.Expires.Equal(expires.UTC().Truncate(time.Second)) {
+		t.Errorf("want expires %v; got %v", expires, s.Expires)
+	}
+
+	if want := []string{"basics", "go"}; !reflect.DeepEqual(s.Tags, want) {
+		t.Errorf("want tags %v; got %v", want, s.Tags)
+	}
+
+	byID, err := m.Get(s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if byID.Slug != slug {
+		t.Errorf("want slug %q; got %q", slug, byID.Slug)
+	}
+
+	_, err = m.GetBySlug("doesnotexist")
+	if !errors.Is(err, models.ErrNoRecord) {
+		t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+	}
+}
+
+func TestSnippetModelExpiry(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "Alice", "alice@example.com")
+
+	m := &SnippetModel{DB: db}
+
+	tests := []struct {
+		name        string
+		expires     *time.Time
+		wantExpired bool
+	}{
+		{"Expired", fromNow(-time.Minute), true},
+		{"Expires soon", fromNow(time.Minute), false},
+		{"Never expires", nil, false},
+	}
+
+	slugs := map[string]string{}
+
+	for _, tt := range tests {
+		slug, err := m.Insert(models.NewSnippet{UserID: userID, Title: tt.name, Files: oneFile("plaintext", "Content"), Visibility: "public", Expires: tt.expires})
+		if err != nil {
+			t.Fatal(err)
+		}
+		slugs[tt.name] = slug
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := m.GetBySlug(slugs[tt.name])
+
+			if tt.wantExpired && !errors.Is(err, models.ErrNoRecord) {
+				t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+			}
+
+			if !tt.wantExpired && err != nil {
+				t.Errorf("want the snippet; got %v", err)
+			}
+		})
+	}
+
+	t.Run("Latest", func(t *testing.T) {
+		_, total, err := m.Latest(10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 2 {
+			t.Errorf("want 2 snippets; got %d", total)
+		}
+	})
+
+	t.Run("DeleteExpired", func(t *testing.T) {
+		deleted, err := m.DeleteExpired(10)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if deleted != 1 {
+			t.Errorf("want 1 deleted; got %d", deleted)
+		}
+	})
+}
+
+func TestSnippetModelSearch(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "Alice", "alice@example.com")
+
+	m := &SnippetModel{DB: db}
+
+	snippets := []struct {
+		title      string
+		content    string
+		visibility string
+	}{
+		{"An old silent pond", "A frog jumps into the pond", "public"},
+		{"Frogs", "Splash! Silence again", "public"},
+		{"100% pure", "under_score", "public"},
+		{"A private frog", "Frog", "private"},
+	}
+
+	for _, s := range snippets {
+		_, err := m.Insert(models.NewSnippet{UserID: userID, Title: s.title, Files: oneFile("plaintext", s.content), Visibility: s.visibility, Expires: fromNow(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		wantTitles []string
+	}{
+		{"Title ranks above content", "frog", []string{"Frogs", "An old silent pond"}},
+		{"Case insensitive", "SILENT", []string{"An old silent pond"}},
+		{"Every term scores", "pond splash", []string{"An old silent pond", "Frogs"}},
+		{"Percent is literal", "%", []string{"100% pure"}},
+		{"Underscore is literal", "r_s", []string{"100% pure"}},
+		{"Underscore isn't a wildcard", "o_t", []string{}},
+		{"No match", "toad", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, total, err := m.Search(tt.query, 10, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			titles := []string{}
+			for _, s := range results {
+				titles = append(titles, s.Title)
+			}
+
+			if !reflect.DeepEqual(titles, tt.wantTitles) {
+				t.Errorf("want %v; got %v", tt.wantTitles, titles)
+			}
+
+			if total != len(tt.wantTitles) {
+				t.Errorf("want total %d; got %d", len(tt.wantTitles), total)
+			}
+		})
+	}
+}
+
+func TestSnippetModelRevisions(t *testing.T) {
+	db := newTestDB(t)
+	aliceID := insertTestUser(t, db, "Alice", "alice@example.com")
+	bobID := insertTestUser(t, db, "Bob", "bob@example.com")
+
+	m := &SnippetModel{DB: db}
+
+	slug, err := m.Insert(models.NewSnippet{UserID: aliceID, Title: "Draft", Files: oneFile("plaintext", "First"), Visibility: "public", Expires: fromNow(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := m.GetBySlug(slug)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Update(models.SnippetUpdate{ID: s.ID, UserID: aliceID, Title: "Final", Files: oneFile("plaintext", "Second"), Visibility: "public"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Other user", func(t *testing.T) {
+		err := m.Update(models.SnippetUpdate{ID: s.ID, UserID: bobID, Title: "Mine", Files: oneFile("plaintext", "Third"), Visibility: "public"})
+
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+	})
+
+	t.Run("Revisions", func(t *testing.T) {
+		revisions, err := m.Revisions(s.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(revisions) != 2 {
+			t.Fatalf("want 2 revisions; got %d", len(revisions))
+		}
+
+		if revisions[0].Revision != 2 || revisions[0].Title != "Final" || revisions[1].Revision != 1 || revisions[1].Title != "Draft" {
+			t.Errorf("want revisions 2 and 1, newest first; got %+v %+v", revisions[0], revisions[1])
+		}
+
+		if revisions[0].UserName != "Alice" {
+			t.Errorf("want user name %q; got %q", "Alice", revisions[0].UserName)
+		}
+	})
+
+	t.Run("Revision", func(t *testing.T) {
+		r, err := m.Revision(s.ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(r.Files) != 1 || r.Files[0].Content != "First" {
+			t.Errorf("want the first revision's files; got %+v", r.Files)
+		}
+
+		_, err = m.Revision(s.ID, 3)
+		if !errors.Is(err, models.ErrNoRecord) {
+			t.Errorf("want %v; got %v", models.ErrNoRecord, err)
+		}
+	})
+
+	t.Run("Updated", func(t *testing.T) {
+		s, err := m.GetBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if s.Title != "Final" || s.Files[0].Content != "Second" {
+			t.Errorf("want the updated snippet; got %q %q", s.Title, s.Files[0].Content)
+		}
+
+		if s.Updated.Before(s.Created) {
+			t.Errorf("want updated %v not before created %v", s.Updated, s.Created)
+		}
+	})
+}
+
+func TestSnippetModelTags(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "Alice", "alice@example.com")
+
+	m := &SnippetModel{DB: db}
+
+	snippets := []struct {
+		title      string
+		tags       []string
+		visibility string
+	}{
+		{"First", []string{"go", "http"}, "public"},
+		{"Second", []string{"go"}, "public"},
+		{"Third", []string{"go"}, "private"},
+		{"Fourth", []string{"sql"}, "public"},
+	}
+
+	ids := map[string]int{}
+
+	for _, s := range snippets {
+		slug, err := m.Insert(models.NewSnippet{UserID: userID, Title: s.title, Files: oneFile("plaintext", "Content"), Tags: s.tags, Visibility: s.visibility, Expires: fromNow(time.Hour)})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		snippet, err := m.GetBySlug(slug)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[s.title] = snippet.ID
+	}
+
+	t.Run("ByTag", func(t *testing.T) {
+		results, total, err := m.ByTag("go", 1, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 2 {
+			t.Errorf("want 2 snippets; got %d", total)
+		}
+
+		if len(results) != 1 || results[0].Title != "Second" {
+			t.Fatalf("want the newest snippet; got %v", results)
+		}
+
+		if want := []string{"go"}; !reflect.DeepEqual(results[0].Tags, want) {
+			t.Errorf("want tags %v; got %v", want, results[0].Tags)
+		}
+
+		results, _, err = m.ByTag("go", 1, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(results) != 1 || results[0].Title != "First" {
+			t.Fatalf("want the second page; got %v", results)
+		}
+
+		if want := []string{"go", "http"}; !reflect.DeepEqual(results[0].Tags, want) {
+			t.Errorf("want tags %v; got %v", want, results[0].Tags)
+		}
+	})
+
+	t.Run("Update replaces tags", func(t *testing.T) {
+		err := m.Update(models.SnippetUpdate{ID: ids["First"], UserID: userID, Title: "First", Files: oneFile("plaintext", "Content"), Tags: []string{"sql"}, Visibility: "public"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, total, err := m.ByTag("go", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 1 {
+			t.Errorf("want 1 go snippet; got %d", total)
+		}
+
+		_, total, err = m.ByTag("sql", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 2 {
+			t.Errorf("want 2 sql snippets; got %d", total)
+		}
+	})
+
+	t.Run("Deleted snippet", func(t *testing.T) {
+		err := m.Delete(ids["Fourth"], userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, total, err := m.ByTag("sql", 10, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if total != 1 {
+			t.Errorf("want 1 sql snippet; got %d", total)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+// Package sqlite implements the models interfaces on top of SQLite, so
+// snippetbox can run without a MySQL server
+// The schema lives in internal/migrations
+// The driver, mattn/go-sqlite3, is a cgo package, so building this package
+// needs cgo enabled and a C compiler
+package sqlite
+
+import (
+	// Register the sqlite3 driver with database/sql
+	_ "github.com/mattn/go-sqlite3"
+)
@@ -0,0 +1,61 @@
+package sqlite
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/koller-m/snippetbox/internal/migrations"
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Open a fresh in-memory database with every migration applied
+// The shared cache keeps it alive across the pool's connections until the
+// pool is closed, which drops it
+func newTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", "file::memory:?cache=shared&_foreign_keys=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator := &migrations.Migrator{DB: db, Dialect: "sqlite"}
+
+	_, err = migrator.Up()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Insert a user for the snippets to belong to
+// Returns the ID of the new user
+func insertTestUser(t *testing.T, db *sql.DB, name, email string) int {
+	users := &UserModel{DB: db}
+
+	err := users.Insert(name, email, "pa$$word1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id int
+
+	err = db.QueryRow(`SELECT id FROM users WHERE email = ?`, email).Scan(&id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return id
+}
+
+// Return the time d from now, for a snippet's expiry
+func fromNow(d time.Duration) *time.Time {
+	t := time.Now().Add(d)
+	return &t
+}
+
+// Return the files of a snippet with a single unnamed file
+func oneFile(language, content string) []*models.SnippetFile {
+	return []*models.SnippetFile{{Language: language, Content: content}}
+}
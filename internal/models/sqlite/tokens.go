@@ -0,0 +1,101 @@
+package sqlite
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/koller-m/snippetbox/internal/models"
+)
+
+// Define TokenModel type which wraps a SQLite sql.DB
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// This will create a new named token for a user
+// Returns the plain-text token, which can't be recovered later
+func (m *TokenModel) Insert(userID int, name string) (string, error) {
+	plaintext, hash, err := models.GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, created)
+	VALUES(?, ?, ?, datetime('now'))`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// This will return all the tokens belonging to a user, newest first
+func (m *TokenModel) List(userID int) ([]*models.Token, error) {
+	stmt := `SELECT id, user_id, name, created FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*models.Token{}
+
+	for rows.Next() {
+		t := &models.Token{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// This will revoke a token belonging to userID
+// Returns models.ErrNoRecord if the user has no such token
+func (m *TokenModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return models.ErrNoRecord
+	}
+
+	return nil
+}
+
+// Authenticate returns the ID of the user the plain-text token belongs to
+// Returns models.ErrInvalidCredentials if it doesn't match any token
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	var userID int
+
+	stmt := "SELECT user_id FROM tokens WHERE hash = ?"
+
+	err := m.DB.QueryRow(stmt, models.HashToken(plaintext)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, models.ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
@@ -0,0 +1,194 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Define StarModelInterface for the methods a star store provides
+// StarModel is the MySQL implementation
+// A user can star each snippet once, and the snippets' Stars fields count them
+type StarModelInterface interface {
+	Insert(userID, snippetID int) error
+	Delete(userID, snippetID int) error
+	Exists(userID, snippetID int) (bool, error)
+	Starred(userID, limit, offset int) ([]*Snippet, int, error)
+}
+
+// Define StarModel type which wraps db connection pool
+type StarModel struct {
+	DB *sql.DB
+}
+
+// This will star a snippet for a user
+// Starring a snippet twice does nothing
+func (m *StarModel) Insert(userID, snippetID int) error {
+	stmt := `INSERT INTO stars (user_id, snippet_id, created) VALUES(?, ?, UTC_TIMESTAMP())
+	ON DUPLICATE KEY UPDATE created = created`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// This will unstar a snippet for a user
+// Unstarring a snippet that isn't starred does nothing
+func (m *StarModel) Delete(userID, snippetID int) error {
+	stmt := `DELETE FROM stars WHERE user_id = ? AND snippet_id = ?`
+
+	_, err := m.DB.Exec(stmt, userID, snippetID)
+	return err
+}
+
+// Exists reports whether the user has starred the snippet
+func (m *StarModel) Exists(userID, snippetID int) (bool, error) {
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM stars WHERE user_id = ? AND snippet_id = ?)"
+
+	err := m.DB.QueryRow(stmt, userID, snippetID).Scan(&exists)
+	return exists, err
+}
+
+// This will return a page of the snippets a user has starred, most recently
+// starred first
+// Snippets made private since are left out, unless they're the user's own
+// Also returns the total number of them for pagination
+func (m *StarModel) Starred(userID, limit, offset int) ([]*Snippet, int, error) {
+	var total int
+
+	stmt := `SELECT COUNT(*) FROM stars st INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE st.user_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND (s.visibility <> 'private' OR s.user_id = st.user_id)`
+
+	err := m.DB.QueryRow(stmt, userID).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt = `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.expires, s.user_id FROM stars st
+	INNER JOIN snippets s ON s.id = st.snippet_id
+	WHERE st.user_id = ? AND (s.expires IS NULL OR s.expires > UTC_TIMESTAMP()) AND (s.visibility <> 'private' OR s.user_id = st.user_id)
+	ORDER BY st.created DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, userID, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
+
+// Fill in the star counts of each of the snippets with a single query
+func loadStars(q querier, snippets []*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Snippet, len(snippets))
+	placeholders := make([]string, 0, len(snippets))
+	args := make([]any, 0, len(snippets))
+
+	for _, s := range snippets {
+		s.Stars = 0
+		byID[s.ID] = s
+		placeholders = append(placeholders, "?")
+		args = append(args, s.ID)
+	}
+
+	stmt := `SELECT snippet_id, COUNT(*) FROM stars
+	WHERE snippet_id IN (` + strings.Join(placeholders, ", ") + `) GROUP BY snippet_id`
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID, stars int
+		err = rows.Scan(&snippetID, &stars)
+		if err != nil {
+			return err
+		}
+		byID[snippetID].Stars = stars
+	}
+
+	return rows.Err()
+}
+
+// This will return a page of public snippets, most starred first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) MostStarred(limit, offset int) ([]*Snippet, int, error) {
+	var total int
+
+	err := m.DB.QueryRow(`SELECT COUNT(*) FROM snippets s WHERE ` + listedSnippets).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt := `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.expires, s.user_id,
+	(SELECT COUNT(*) FROM stars st WHERE st.snippet_id = s.id) AS stars FROM snippets s
+	WHERE ` + listedSnippets + `
+	ORDER BY stars DESC, s.created DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID, &s.Stars)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
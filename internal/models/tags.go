@@ -0,0 +1,152 @@
+package models
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Replace the tags of a snippet, creating any that don't exist yet
+func setTags(tx *sql.Tx, snippetID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM snippet_tags WHERE snippet_id = ?`, snippetID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(`INSERT INTO tags (name) VALUES(?) ON DUPLICATE KEY UPDATE name = name`, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(`INSERT INTO snippet_tags (snippet_id, tag_id) SELECT ?, id FROM tags WHERE name = ?`, snippetID, tag)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Return the tags of a snippet, sorted by name
+func (m *SnippetModel) tags(snippetID int) ([]string, error) {
+	stmt := `SELECT t.name FROM tags t INNER JOIN snippet_tags st ON st.tag_id = t.id 
+	WHERE st.snippet_id = ? ORDER BY t.name`
+
+	rows, err := m.DB.Query(stmt, snippetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+
+	for rows.Next() {
+		var tag string
+		err = rows.Scan(&tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Fill in the tags of each of the snippets with a single query
+func loadTags(q querier, snippets []*Snippet) error {
+	if len(snippets) == 0 {
+		return nil
+	}
+
+	byID := make(map[int]*Snippet, len(snippets))
+	placeholders := make([]string, 0, len(snippets))
+	args := make([]any, 0, len(snippets))
+
+	for _, s := range snippets {
+		s.Tags = []string{}
+		byID[s.ID] = s
+		placeholders = append(placeholders, "?")
+		args = append(args, s.ID)
+	}
+
+	stmt := `SELECT st.snippet_id, t.name FROM tags t INNER JOIN snippet_tags st ON st.tag_id = t.id 
+	WHERE st.snippet_id IN (` + strings.Join(placeholders, ", ") + `) ORDER BY t.name`
+
+	rows, err := q.Query(stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var snippetID int
+		var tag string
+		err = rows.Scan(&snippetID, &tag)
+		if err != nil {
+			return err
+		}
+		byID[snippetID].Tags = append(byID[snippetID].Tags, tag)
+	}
+
+	return rows.Err()
+}
+
+// This will return a page of public snippets with the given tag, newest first
+// Also returns the total number of them for pagination
+func (m *SnippetModel) ByTag(tag string, limit, offset int) ([]*Snippet, int, error) {
+	var total int
+
+	stmt := `SELECT COUNT(*) FROM snippets s 
+	INNER JOIN snippet_tags st ON st.snippet_id = s.id INNER JOIN tags t ON t.id = st.tag_id 
+	WHERE ` + listedSnippets + ` AND t.name = ?`
+
+	err := m.DB.QueryRow(stmt, tag).Scan(&total)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	stmt = `SELECT s.id, s.slug, s.title, s.visibility, s.burn_after_reading, s.hashed_password, s.created, s.expires, s.user_id FROM snippets s 
+	INNER JOIN snippet_tags st ON st.snippet_id = s.id INNER JOIN tags t ON t.id = st.tag_id 
+	WHERE ` + listedSnippets + ` AND t.name = ? 
+	ORDER BY s.created DESC, s.id DESC LIMIT ? OFFSET ?`
+
+	rows, err := m.DB.Query(stmt, tag, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	snippets := []*Snippet{}
+
+	for rows.Next() {
+		s := &Snippet{}
+		err = rows.Scan(&s.ID, &s.Slug, &s.Title, &s.Visibility, &s.BurnAfterReading, &s.HashedPassword, &s.Created, &s.Expires, &s.UserID)
+		if err != nil {
+			return nil, 0, err
+		}
+		snippets = append(snippets, s)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	err = loadFiles(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadTags(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	err = loadStars(m.DB, snippets)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snippets, total, nil
+}
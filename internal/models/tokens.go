@@ -0,0 +1,143 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// Define Token type for a personal API token
+// Only a SHA-256 hash of the token is stored, the plain-text token is shown
+// to the user once when it's created
+type Token struct {
+	ID      int
+	UserID  int
+	Name    string
+	Created time.Time
+}
+
+// Define TokenModelInterface for the methods a token store provides
+// TokenModel is the MySQL implementation
+type TokenModelInterface interface {
+	Insert(userID int, name string) (string, error)
+	List(userID int) ([]*Token, error)
+	Delete(id, userID int) error
+	Authenticate(plaintext string) (int, error)
+}
+
+// Define TokenModel type which wraps db connection pool
+type TokenModel struct {
+	DB *sql.DB
+}
+
+// GenerateToken returns a random URL-safe token and its hash
+// 32 bytes from crypto/rand is too much entropy to need a slow hash like bcrypt
+func GenerateToken() (string, []byte, error) {
+	b := make([]byte, 32)
+
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", nil, err
+	}
+
+	plaintext := base64.RawURLEncoding.EncodeToString(b)
+
+	return plaintext, HashToken(plaintext), nil
+}
+
+// HashToken returns the SHA-256 hash stored for a plain-text token
+func HashToken(plaintext string) []byte {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hash[:]
+}
+
+// This will create a new named token for a user
+// Returns the plain-text token, which can't be recovered later
+func (m *TokenModel) Insert(userID int, name string) (string, error) {
+	plaintext, hash, err := GenerateToken()
+	if err != nil {
+		return "", err
+	}
+
+	stmt := `INSERT INTO tokens (user_id, name, hash, created)
+	VALUES(?, ?, ?, UTC_TIMESTAMP())`
+
+	_, err = m.DB.Exec(stmt, userID, name, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return plaintext, nil
+}
+
+// This will return all the tokens belonging to a user, newest first
+func (m *TokenModel) List(userID int) ([]*Token, error) {
+	stmt := `SELECT id, user_id, name, created FROM tokens
+	WHERE user_id = ? ORDER BY id DESC`
+
+	rows, err := m.DB.Query(stmt, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		t := &Token{}
+		err = rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Created)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// This will revoke a token belonging to userID
+// Returns ErrNoRecord if the user has no such token
+func (m *TokenModel) Delete(id, userID int) error {
+	stmt := `DELETE FROM tokens WHERE id = ? AND user_id = ?`
+
+	result, err := m.DB.Exec(stmt, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// Authenticate returns the ID of the user the plain-text token belongs to
+// Returns ErrInvalidCredentials if it doesn't match any token
+func (m *TokenModel) Authenticate(plaintext string) (int, error) {
+	var userID int
+
+	stmt := "SELECT user_id FROM tokens WHERE hash = ?"
+
+	err := m.DB.QueryRow(stmt, HashToken(plaintext)).Scan(&userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, ErrInvalidCredentials
+		} else {
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
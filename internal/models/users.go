@@ -20,6 +20,14 @@ type User struct {
 	Created        time.Time
 }
 
+// Define UserModelInterface for the methods a user store provides
+// UserModel is the MySQL implementation
+type UserModelInterface interface {
+	Insert(name, email, password string) error
+	Authenticate(email, password string) (int, error)
+	Exists(id int) (bool, error)
+}
+
 // Define UserModel type which wraps db connection pool
 type UserModel struct {
 	DB *sql.DB
@@ -90,5 +98,10 @@ func (m *UserModel) Authenticate(email, password string) (int, error) {
 
 // Check if the users exists with the specific ID
 func (m *UserModel) Exists(id int) (bool, error) {
-	return false, nil
+	var exists bool
+
+	stmt := "SELECT EXISTS(SELECT true FROM users WHERE id = ?)"
+
+	err := m.DB.QueryRow(stmt, id).Scan(&exists)
+	return exists, err
 }
@@ -3,6 +3,7 @@ package validator
 import (
 	"regexp"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 )
 
@@ -18,6 +19,13 @@ type Validator struct {
 // Or panics in the event of an error
 var EmailRX = regexp.MustCompile("^[a-zA-Z0-9.!#$%&'*+\\/=?^_`{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)*$")
 
+// TagRX matches a normalized tag, which starts with a lowercase letter or
+// digit followed by any of those and - + # . for names like c++ and node.js
+var TagRX = regexp.MustCompile(`^[a-z0-9][a-z0-9+#.-]*$`)
+
+// Maximum length of a tag, matching the tags.name column
+const MaxTagChars = 30
+
 // Valid() returns true if the FieldErrors map is empty
 func (v *Validator) Valid() bool {
 	return len(v.FieldErrors) == 0 && len(v.NonFieldErrors) == 0
@@ -76,3 +84,42 @@ func MinChars(value string, n int) bool {
 func Matches(value string, rx *regexp.Regexp) bool {
 	return rx.MatchString(value)
 }
+
+// PermittedValue() returns true if the value is in the list of permitted values
+// Generic version of PermittedInt() for strings and other comparable types
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	for i := range permittedValues {
+		if value == permittedValues[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeTags() splits a list of tags separated by commas or spaces
+// Tags are lowercased, a leading # is dropped, and so are duplicates
+func NormalizeTags(value string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	split := func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	}
+
+	for _, tag := range strings.FieldsFunc(strings.ToLower(value), split) {
+		tag = strings.TrimLeft(tag, "#")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// ValidTag() returns true if a normalized tag matches TagRX and is at most
+// MaxTagChars long
+func ValidTag(tag string) bool {
+	return MaxChars(tag, MaxTagChars) && Matches(tag, TagRX)
+}
@@ -0,0 +1,11 @@
+package ui
+
+import (
+	"embed"
+)
+
+// Embed the templates and static files into the binary
+// This means the server (and the tests) don't depend on the working directory
+//
+//go:embed "html" "static"
+var Files embed.FS
@@ -5,4 +5,23 @@ for (var i = 0; i < navLinks.length; i++) {
 		link.classList.add("live");
 		break;
 	}
-}
\ No newline at end of file
+}
+
+// Add and remove the file blocks of the snippet forms
+var files = document.querySelector(".files");
+if (files) {
+	var fileTemplate = document.getElementById("file-template");
+	var nextFile = files.querySelectorAll("fieldset.file").length;
+
+	files.classList.add("scripted");
+
+	files.addEventListener("click", function (e) {
+		if (e.target.classList.contains("add-file")) {
+			// Indexes only need to be unique, the server drops any gaps
+			var block = fileTemplate.innerHTML.replace(/__index__/g, nextFile++);
+			e.target.insertAdjacentHTML("beforebegin", block);
+		} else if (e.target.classList.contains("remove-file")) {
+			e.target.closest("fieldset.file").remove();
+		}
+	});
+}